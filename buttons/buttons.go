@@ -1,10 +1,11 @@
 package buttons
 
-// All coordinates in screen coord system e.g
+// All coordinates in logical coord system (see metrics.go) e.g
 // (0, 0) = (left, top) of the current window
 
 import (
 	"videoplayer/shaders"
+	"videoplayer/text"
 
 	"github.com/go-gl/gl/v4.1-core/gl"
 	"github.com/go-gl/glfw/v3.3/glfw"
@@ -21,6 +22,7 @@ type ButtonsBar struct {
 	scrollerHandle *Handle
 	soundVolume    *SoundVolume
 	soundHandle    *SoundHandle
+	timeDisplay    *TimeDisplay
 	buttons        map[string]*Button
 	window         *glfw.Window
 	pos            *mgl32.Vec2 // (x1, y1) center of the buttonsBar in window coord system
@@ -31,9 +33,10 @@ type ButtonsBar struct {
 func NewButtonsBar(
 	w *glfw.Window,
 	sh, vao uint32,
+	textRenderer *text.Renderer,
 	visible bool,
 ) *ButtonsBar {
-	wWidth, _ := w.GetSize()
+	wWidth, _ := LogicalSize(w)
 	buttons := make(map[string]*Button)
 	buttons["play"] = NewButton(
 		w,
@@ -61,7 +64,7 @@ func NewButtonsBar(
 	)
 	scroller := NewScroller(
 		w,
-		float32(wWidth),
+		wWidth,
 		10,
 		&mgl32.Vec4{1, 0.1, 0.8, 1},
 	)
@@ -83,14 +86,21 @@ func NewButtonsBar(
 		15,
 		&mgl32.Vec4{1, 0.1, 0.8, 1},
 	)
+	timeDisplay := NewTimeDisplay(
+		w,
+		textRenderer,
+		15,
+		mgl32.Vec4{0.1, 0.1, 0.1, 1},
+	)
 	buttonsBar := &ButtonsBar{
-		width:          float32(wWidth),
+		width:          wWidth,
 		height:         60,
 		visible:        true,
 		scroller:       scroller,
 		scrollerHandle: scrollerHandle,
 		soundVolume:    soundVolume,
 		soundHandle:    soundHandle,
+		timeDisplay:    timeDisplay,
 		buttons:        buttons,
 		window:         w,
 		sh:             sh,
@@ -144,21 +154,13 @@ func (bb *ButtonsBar) Draw() {
 	}
 
 	gl.BindVertexArray(0)
+
+	bb.timeDisplay.Draw()
 }
 
-// Transform screen coordinates to OpenGL world view coordinates
+// Transform logical coordinates to OpenGL world view coordinates
 func (bb *ButtonsBar) SetMatrix() {
-	wWidth, wHeight := bb.window.GetSize()
-	scaleX := 2 * bb.width / float32(wWidth)
-	scaleY := bb.height / float32(wHeight)
-
-	// transforms (0, 0) in screen coord to (-1, 1) in OpenGL coords
-	translateX := 2*bb.pos.X()/float32(wWidth) - 1
-	translateY := -2*bb.pos.Y()/float32(wHeight) + 1
-
-	matrix := mgl32.Translate3D(translateX, translateY, 0).
-		Mul4(mgl32.Scale3D(scaleX, scaleY, 1))
-	bb.matrix = &matrix
+	bb.matrix = quadMatrix(bb.window, *bb.pos, bb.width, bb.height)
 }
 
 func (bb *ButtonsBar) IsMouseOver(x, y float32) bool {
@@ -174,6 +176,16 @@ func (bb *ButtonsBar) IsMouseOver(x, y float32) bool {
 	return false
 }
 
+// Top edge of the controls (scroller handle included)
+func (bb *ButtonsBar) Top() float32 {
+	top := bb.pos.Y() - bb.height/2
+	handle := bb.scrollerHandle
+	if handleTop := handle.pos.Y() - handle.height/2; handleTop < top {
+		return handleTop
+	}
+	return top
+}
+
 func (bb *ButtonsBar) GetVisibility() bool {
 	return bb.visible
 }
@@ -194,15 +206,20 @@ func (bb *ButtonsBar) GetSoundVolume() *SoundVolume {
 	return bb.soundVolume
 }
 
+func (bb *ButtonsBar) GetTimeDisplay() *TimeDisplay {
+	return bb.timeDisplay
+}
+
 func (bb *ButtonsBar) UpdatePos() {
-	wWidth, wHeight := bb.window.GetSize()
-	bb.pos = &mgl32.Vec2{float32(wWidth) / 2, float32(wHeight) - 30}
-	bb.width = float32(wWidth)
+	wWidth, wHeight := LogicalSize(bb.window)
+	bb.pos = &mgl32.Vec2{wWidth / 2, wHeight - 30}
+	bb.width = wWidth
 	bb.SetMatrix()
 	bb.scroller.UpdatePos()
 	bb.scrollerHandle.UpdatePos()
 	bb.soundVolume.UpdatePos()
 	bb.soundHandle.UpdatePos()
+	bb.timeDisplay.UpdatePos()
 	for _, button := range bb.buttons {
 		button.UpdatePos()
 	}
@@ -216,17 +233,17 @@ func (bb *ButtonsBar) UpdatePos() {
 //
 // x=1 -> right corner
 func (bb *ButtonsBar) MoveScrollerHandle(x float32) {
-	wWidth, _ := bb.window.GetSize()
-	xPos := x * float32(wWidth)
+	wWidth, _ := LogicalSize(bb.window)
+	xPos := x * wWidth
 	bb.scrollerHandle.Move(xPos)
 }
 
 // Moves sound volume handle along X axis
 
-// x represents window coordinats
+// x represents logical coordinates
 func (bb *ButtonsBar) MoveSoundHandle(x float32) {
-	wWidth, _ := bb.window.GetSize()
-	if int(x) >= wWidth-140 && int(x) <= wWidth-20 {
+	wWidth, _ := LogicalSize(bb.window)
+	if x >= wWidth-140 && x <= wWidth-20 {
 		bb.soundHandle.Move(x)
 	}
 }
@@ -262,19 +279,12 @@ func NewButton(
 }
 
 func (b *Button) SetMatrix() {
-	wWidth, wHeight := b.window.GetSize()
-	scaleX := b.width / float32(wWidth)
-	scaleY := b.height / float32(wHeight)
-	translateX := 2*b.pos.X()/float32(wWidth) - 1
-	translateY := -2*b.pos.Y()/float32(wHeight) + 1
-	matrix := mgl32.Translate3D(translateX, translateY, 0).
-		Mul4(mgl32.Scale3D(scaleX, scaleY, 1))
-	b.matrix = &matrix
+	b.matrix = quadMatrix(b.window, *b.pos, b.width, b.height)
 }
 
 func (b *Button) UpdatePos() {
-	wWidth, wHeight := b.window.GetSize()
-	b.pos = &mgl32.Vec2{float32(wWidth)/2 + b.offsetX, float32(wHeight) - 30}
+	wWidth, wHeight := LogicalSize(b.window)
+	b.pos = &mgl32.Vec2{wWidth/2 + b.offsetX, wHeight - 30}
 	b.SetMatrix()
 }
 
@@ -317,24 +327,14 @@ func NewScroller(
 }
 
 func (s *Scroller) UpdatePos() {
-	wWidth, wHeight := s.window.GetSize()
-	s.pos = &mgl32.Vec2{float32(wWidth) / 2, float32(wHeight) - 70}
-	s.width = float32(wWidth)
+	wWidth, wHeight := LogicalSize(s.window)
+	s.pos = &mgl32.Vec2{wWidth / 2, wHeight - 70}
+	s.width = wWidth
 	s.SetMatrix()
 }
 
 func (s *Scroller) SetMatrix() {
-	wWidth, wHeight := s.window.GetSize()
-	scaleX := 2 * s.width / float32(wWidth)
-	scaleY := s.height / float32(wHeight)
-
-	// transforms (0, 0) in screen coord to (-1, 1) in OpenGL coords
-	translateX := 2*s.pos.X()/float32(wWidth) - 1
-	translateY := -2*s.pos.Y()/float32(wHeight) + 1
-
-	matrix := mgl32.Translate3D(translateX, translateY, 0).
-		Mul4(mgl32.Scale3D(scaleX, scaleY, 1))
-	s.matrix = &matrix
+	s.matrix = quadMatrix(s.window, *s.pos, s.width, s.height)
 }
 
 func (s *Scroller) IsMouseOver(x, y float32) bool {
@@ -375,21 +375,13 @@ func NewHandle(
 }
 
 func (h *Handle) UpdatePos() {
-	_, wHeight := h.window.GetSize()
-	h.pos = &mgl32.Vec2{0, float32(wHeight) - 70}
+	_, wHeight := LogicalSize(h.window)
+	h.pos = &mgl32.Vec2{0, wHeight - 70}
 	h.SetMatrix()
 }
 
 func (h *Handle) SetMatrix() {
-	wWidth, wHeight := h.window.GetSize()
-	scaleX := h.width / float32(wWidth)
-	scaleY := h.height / float32(wHeight)
-	translateX := 2*h.pos.X()/float32(wWidth) - 1
-	translateY := -2*h.pos.Y()/float32(wHeight) + 1
-
-	matrix := mgl32.Translate3D(translateX, translateY, 0).
-		Mul4(mgl32.Scale3D(scaleX, scaleY, 1))
-	h.matrix = &matrix
+	h.matrix = quadMatrix(h.window, *h.pos, h.width, h.height)
 }
 
 func (h *Handle) Move(x float32) {
@@ -423,20 +415,13 @@ func NewSoundVolume(
 }
 
 func (sv *SoundVolume) UpdatePos() {
-	wWidth, wHeight := sv.window.GetSize()
-	sv.pos = &mgl32.Vec2{float32(wWidth) - 80, float32(wHeight) - 30}
+	wWidth, wHeight := LogicalSize(sv.window)
+	sv.pos = &mgl32.Vec2{wWidth - 80, wHeight - 30}
 	sv.SetMatrix()
 }
 
 func (sv *SoundVolume) SetMatrix() {
-	wWidth, wHeight := sv.window.GetSize()
-	scaleX := sv.width / float32(wWidth)
-	scaleY := sv.height / float32(wHeight)
-	translateX := 2*sv.pos.X()/float32(wWidth) - 1
-	translateY := -2*sv.pos.Y()/float32(wHeight) + 1
-	matrix := mgl32.Translate3D(translateX, translateY, 0).
-		Mul4(mgl32.Scale3D(scaleX, scaleY, 1))
-	sv.matrix = &matrix
+	sv.matrix = quadMatrix(sv.window, *sv.pos, sv.width, sv.height)
 }
 
 func (sv *SoundVolume) IsMouseOver(x, y float32) bool {
@@ -477,21 +462,13 @@ func NewSoundHandle(
 }
 
 func (sHandle *SoundHandle) UpdatePos() {
-	wWidth, wHeight := sHandle.window.GetSize()
-	sHandle.pos = &mgl32.Vec2{float32(wWidth) - 80, float32(wHeight) - 30}
+	wWidth, wHeight := LogicalSize(sHandle.window)
+	sHandle.pos = &mgl32.Vec2{wWidth - 80, wHeight - 30}
 	sHandle.SetMatrix()
 }
 
 func (sHandle *SoundHandle) SetMatrix() {
-	wWidth, wHeight := sHandle.window.GetSize()
-	scaleX := sHandle.width / float32(wWidth)
-	scaleY := sHandle.height / float32(wHeight)
-	translateX := 2*sHandle.pos.X()/float32(wWidth) - 1
-	translateY := -2*sHandle.pos.Y()/float32(wHeight) + 1
-
-	matrix := mgl32.Translate3D(translateX, translateY, 0).
-		Mul4(mgl32.Scale3D(scaleX, scaleY, 1))
-	sHandle.matrix = &matrix
+	sHandle.matrix = quadMatrix(sHandle.window, *sHandle.pos, sHandle.width, sHandle.height)
 }
 
 func (sHandle *SoundHandle) Move(x float32) {
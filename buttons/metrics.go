@@ -0,0 +1,66 @@
+package buttons
+
+// Layout is done in logical coordinates: framebuffer pixels divided by
+// the content scale of the window, so controls keep their size on scaled (HiDPI) displays.
+// Cursor positions come in window (screen) coordinates, which may differ from both
+
+import (
+	"math"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Content scale of the window, 1 on regular displays
+func ContentScale(w *glfw.Window) float32 {
+	scale, _ := w.GetContentScale()
+	if scale <= 0 {
+		return 1
+	}
+	return scale
+}
+
+// Window size in logical coordinates
+func LogicalSize(w *glfw.Window) (float32, float32) {
+	fbWidth, fbHeight := w.GetFramebufferSize()
+	scale := ContentScale(w)
+	return float32(fbWidth) / scale, float32(fbHeight) / scale
+}
+
+// Converts window coordinates (e.g. cursor position) to logical ones
+func ToLogical(w *glfw.Window, x, y float64) (float32, float32) {
+	wWidth, wHeight := w.GetSize()
+	if wWidth == 0 || wHeight == 0 {
+		return 0, 0
+	}
+	lWidth, lHeight := LogicalSize(w)
+	return float32(x) * lWidth / float32(wWidth), float32(y) * lHeight / float32(wHeight)
+}
+
+// Cursor position in logical coordinates
+func CursorPos(w *glfw.Window) (float32, float32) {
+	x, y := w.GetCursorPos()
+	return ToLogical(w, x, y)
+}
+
+// Transforms [-1, 1] quad into rectangle with the centre and size in logical coordinates.
+// Edges are snapped to framebuffer pixels, so controls stay crisp
+func quadMatrix(w *glfw.Window, centre mgl32.Vec2, width, height float32) *mgl32.Mat4 {
+	fbWidth, fbHeight := w.GetFramebufferSize()
+	scale := ContentScale(w)
+	toPixels := func(x float32) float32 {
+		return float32(math.Round(float64(x * scale)))
+	}
+	left, right := toPixels(centre.X()-width/2), toPixels(centre.X()+width/2)
+	top, bottom := toPixels(centre.Y()-height/2), toPixels(centre.Y()+height/2)
+
+	// transforms (0, 0) in framebuffer pixels to (-1, 1) in OpenGL coords
+	scaleX := (right - left) / float32(fbWidth)
+	scaleY := (bottom - top) / float32(fbHeight)
+	translateX := (left+right)/float32(fbWidth) - 1
+	translateY := 1 - (top+bottom)/float32(fbHeight)
+
+	matrix := mgl32.Translate3D(translateX, translateY, 0).
+		Mul4(mgl32.Scale3D(scaleX, scaleY, 1))
+	return &matrix
+}
@@ -0,0 +1,139 @@
+package buttons
+
+// Playback time shown at the left side of the buttons bar:
+// elapsed (or remaining) time and duration of the video
+
+import (
+	"fmt"
+	"math"
+	"time"
+	"videoplayer/text"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+type TimeFormat int
+
+const (
+	TimeClock    TimeFormat = iota // [h:]mm:ss
+	TimeFrames                     // frame numbers
+	TimeTimecode                   // SMPTE hh:mm:ss:ff (non-drop frame)
+)
+
+var timeFormatNames = []string{"clock", "frames", "timecode"}
+
+func ParseTimeFormat(name string) (TimeFormat, error) {
+	for i, n := range timeFormatNames {
+		if n == name {
+			return TimeFormat(i), nil
+		}
+	}
+	return TimeClock, fmt.Errorf("unknown time format: %s", name)
+}
+
+func (f TimeFormat) String() string {
+	return timeFormatNames[f]
+}
+
+type TimeDisplay struct {
+	Format        TimeFormat
+	Remaining     bool          // remaining time is shown instead of the elapsed one
+	FrameDuration time.Duration // used by frame number and timecode formats
+	position      time.Duration
+	duration      time.Duration
+	window        *glfw.Window
+	renderer      *text.Renderer
+	style         text.Style
+	pos           *mgl32.Vec2 // (x1, y1) left edge and vertical center of the text
+	width         float32     // size of the last drawn text
+	height        float32
+}
+
+func NewTimeDisplay(
+	w *glfw.Window,
+	renderer *text.Renderer,
+	size float32,
+	color mgl32.Vec4,
+) *TimeDisplay {
+	style := text.DefaultStyle()
+	style.Size = size
+	style.Color = color
+	// bar is plain, there is no need in outline and shadow
+	style.Outline = 0
+	style.Shadow = mgl32.Vec2{}
+	timeDisplay := &TimeDisplay{
+		window:   w,
+		renderer: renderer,
+		style:    style,
+	}
+	timeDisplay.UpdatePos()
+	return timeDisplay
+}
+
+func (td *TimeDisplay) UpdatePos() {
+	_, wHeight := LogicalSize(td.window)
+	td.pos = &mgl32.Vec2{15, wHeight - 30}
+}
+
+// Sets playback position and duration of the video
+func (td *TimeDisplay) Set(position, duration time.Duration) {
+	if position > duration {
+		position = duration
+	}
+	td.position, td.duration = position, duration
+}
+
+// Switches between elapsed and remaining time
+func (td *TimeDisplay) Toggle() {
+	td.Remaining = !td.Remaining
+}
+
+func (td *TimeDisplay) Text() string {
+	current := td.format(td.position)
+	if td.Remaining {
+		current = "-" + td.format(td.duration-td.position)
+	}
+	return current + " / " + td.format(td.duration)
+}
+
+func (td *TimeDisplay) format(t time.Duration) string {
+	if td.Format != TimeClock && td.FrameDuration > 0 {
+		frame := int64(t / td.FrameDuration)
+		if td.Format == TimeFrames {
+			return fmt.Sprint(frame)
+		}
+		// frames are counted with the nominal integer rate (e.g. 30 for 29.97 fps)
+		rate := int64(math.Round(float64(time.Second) / float64(td.FrameDuration)))
+		if rate < 1 {
+			rate = 1
+		}
+		seconds := frame / rate
+		return fmt.Sprintf("%02d:%02d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60, frame%rate)
+	}
+
+	seconds := int64(t / time.Second)
+	if td.duration >= time.Hour {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%02d:%02d", seconds/60, seconds%60)
+}
+
+func (td *TimeDisplay) Draw() {
+	label := td.Text()
+	td.width, td.height = td.renderer.Measure(label, td.style)
+	td.renderer.Draw(label, td.pos.X(), td.pos.Y()-td.height/2, td.style)
+}
+
+func (td *TimeDisplay) IsMouseOver(x, y float32) bool {
+	xLeftEdge := td.pos.X()
+	xRightEdge := td.pos.X() + td.width
+	yTopEdge := td.pos.Y() - td.height/2
+	yBottomEdge := td.pos.Y() + td.height/2
+
+	if x >= xLeftEdge && x <= xRightEdge &&
+		y >= yTopEdge && y <= yBottomEdge {
+		return true
+	}
+	return false
+}
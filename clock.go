@@ -0,0 +1,48 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Playback position driven by audio samples consumed by the speaker,
+// video frames are synchronized to it
+type MediaClock struct {
+	audioPts time.Duration // presentation time of the audio frame being played
+	samples  int           // samples played since the audio frame start
+	mu       sync.Mutex
+}
+
+// Marks the start of the audio frame in the sample buffer
+type audioTimestamp time.Duration
+
+// Current playback position
+func (c *MediaClock) Time() time.Duration {
+	c.mu.Lock()
+	t := c.audioPts + time.Duration(c.samples)*time.Second/sampleRate - speakerLatency
+	c.mu.Unlock()
+	if t < 0 {
+		return 0
+	}
+	return t
+}
+
+// Called when speaker starts playing samples of the next audio frame
+func (c *MediaClock) SetAudioPts(pts time.Duration) {
+	c.mu.Lock()
+	c.audioPts = pts
+	c.samples = 0
+	c.mu.Unlock()
+}
+
+// Called when speaker consumed n samples
+func (c *MediaClock) AddSamples(n int) {
+	c.mu.Lock()
+	c.samples += n
+	c.mu.Unlock()
+}
+
+// Moves the clock to t (e.g. on rewind) until next audio frame is played
+func (c *MediaClock) Reset(t time.Duration) {
+	c.SetAudioPts(t + speakerLatency)
+}
@@ -0,0 +1,117 @@
+package container
+
+// Reading of the stream metadata which the decoder doesn't expose
+// straight from MP4/MOV and Matroska/WebM containers
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"os"
+)
+
+// Codes of the colour description (ISO/IEC 23091-2, same as in H.264/H.265 VUI)
+const (
+	PrimariesBT709  = 1
+	PrimariesBT2020 = 9
+
+	TransferBT709 = 1
+	TransferPQ    = 16 // SMPTE ST 2084
+	TransferHLG   = 18 // ARIB STD-B67
+
+	MatrixBT709   = 1
+	MatrixBT470BG = 5 // BT.601 625 lines
+	MatrixBT601   = 6 // BT.601 525 lines
+	MatrixBT2020  = 9
+)
+
+type FieldOrder int
+
+const (
+	FieldOrderUnknown FieldOrder = iota
+	Progressive
+	Interlaced // field order is unknown
+	TopFieldFirst
+	BottomFieldFirst
+	FieldsPossible // only codec parameters are known: they allow field pictures, frames may still be progressive
+)
+
+// Metadata of the first video track, zero values mean unknown
+type VideoInfo struct {
+	ColorPrimaries int
+	ColorTransfer  int
+	ColorMatrix    int
+	FullRange      bool
+	FieldOrder     FieldOrder
+	Rotation       int  // clockwise rotation needed for display in degrees: 0, 90, 180 or 270
+	Mirrored       bool // picture has to be flipped horizontally (after rotation)
+}
+
+// Rounds angle in degrees to the closest multiple of 90 in [0, 360)
+func roundRotation(angle float64) int {
+	rotation := int(math.Round(angle/90)) * 90 % 360
+	if rotation < 0 {
+		rotation += 360
+	}
+	return rotation
+}
+
+// Field order by QuickTime fiel/Matroska FieldOrder detail code
+func fieldOrderFromCode(code int) FieldOrder {
+	switch code {
+	case 0:
+		return Progressive
+	case 1, 14: // top field is displayed first
+		return TopFieldFirst
+	case 6, 9:
+		return BottomFieldFirst
+	}
+	return Interlaced
+}
+
+type format int
+
+const (
+	formatUnknown format = iota
+	formatMP4
+	formatMKV
+)
+
+// Reads video metadata of the file.
+// Returns empty info for unsupported containers
+func Probe(path string) (*VideoInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	info := &VideoInfo{}
+	switch detectFormat(f) {
+	case formatMP4:
+		err = probeMP4(f, stat.Size(), info)
+	case formatMKV:
+		err = probeMKV(f, stat.Size(), info)
+	}
+	return info, err
+}
+
+func detectFormat(r io.ReaderAt) format {
+	header := make([]byte, 12)
+	_, err := r.ReadAt(header, 0)
+	if err != nil {
+		return formatUnknown
+	}
+	switch {
+	case bytes.Equal(header[:4], []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		return formatMKV
+	case string(header[4:8]) == "ftyp" || string(header[4:8]) == "moov" ||
+		string(header[4:8]) == "mdat" || string(header[4:8]) == "wide":
+		return formatMP4
+	}
+	return formatUnknown
+}
@@ -0,0 +1,198 @@
+package container
+
+// Parsing of H.264 sequence parameter set, just as far as frame_mbs_only_flag.
+// The flag tells only whether field pictures (PAFF/MBAFF) are allowed:
+// progressive streams are often coded with it too and the actual structure
+// is signalled per picture (field_pic_flag, pic_struct), which isn't parsed
+
+import (
+	"errors"
+)
+
+var errShortSPS = errors.New("h264: sequence parameter set is too short")
+
+// Limit of num_ref_frames_in_pic_order_cnt_cycle
+const maxPOCCycle = 255
+
+// Reads bits of RBSP (NAL unit payload without emulation prevention bytes)
+type bitReader struct {
+	data []byte
+	pos  int // in bits
+}
+
+func (br *bitReader) bit() (uint, error) {
+	if br.pos >= len(br.data)*8 {
+		return 0, errShortSPS
+	}
+	b := br.data[br.pos/8] >> (7 - br.pos%8) & 1
+	br.pos++
+	return uint(b), nil
+}
+
+func (br *bitReader) bits(n int) (uint, error) {
+	value := uint(0)
+	for i := 0; i < n; i++ {
+		b, err := br.bit()
+		if err != nil {
+			return 0, err
+		}
+		value = value<<1 | b
+	}
+	return value, nil
+}
+
+// Unsigned Exp-Golomb code
+func (br *bitReader) ue() (uint, error) {
+	zeros := 0
+	for {
+		b, err := br.bit()
+		if err != nil {
+			return 0, err
+		}
+		if b == 1 {
+			break
+		}
+		zeros++
+		if zeros > 31 {
+			return 0, errors.New("h264: invalid Exp-Golomb code")
+		}
+	}
+	rest, err := br.bits(zeros)
+	return 1<<zeros - 1 + rest, err
+}
+
+// Signed Exp-Golomb code
+func (br *bitReader) se() (int, error) {
+	code, err := br.ue()
+	if code%2 == 1 {
+		return int(code+1) / 2, err
+	}
+	return -int(code / 2), err
+}
+
+// Removes emulation prevention bytes (00 00 03 -> 00 00)
+func unescapeRBSP(nal []byte) []byte {
+	rbsp := make([]byte, 0, len(nal))
+	zeros := 0
+	for _, b := range nal {
+		if zeros >= 2 && b == 3 {
+			zeros = 0
+			continue
+		}
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		rbsp = append(rbsp, b)
+	}
+	return rbsp
+}
+
+// profiles which have chroma format and scaling matrices in SPS
+var highProfiles = map[uint]bool{
+	100: true, 110: true, 122: true, 244: true, 44: true, 83: true, 86: true,
+	118: true, 128: true, 138: true, 139: true, 134: true, 135: true,
+}
+
+// Reports whether the stream may contain field pictures
+// (frame_mbs_only_flag is 0), sps is NAL unit including its header
+func spsAllowsFields(sps []byte) (bool, error) {
+	if len(sps) < 4 {
+		return false, errShortSPS
+	}
+	br := &bitReader{data: unescapeRBSP(sps[1:])}
+	profile, _ := br.bits(8)
+	br.bits(16) // constraint flags and level
+	br.ue()     // seq_parameter_set_id
+
+	if highProfiles[profile] {
+		chromaFormat, _ := br.ue()
+		if chromaFormat == 3 {
+			br.bit() // separate_colour_plane_flag
+		}
+		br.ue()  // bit_depth_luma_minus8
+		br.ue()  // bit_depth_chroma_minus8
+		br.bit() // qpprime_y_zero_transform_bypass_flag
+		scalingMatrix, _ := br.bit()
+		if scalingMatrix == 1 {
+			lists := 8
+			if chromaFormat == 3 {
+				lists = 12
+			}
+			for i := 0; i < lists; i++ {
+				present, _ := br.bit()
+				if present == 0 {
+					continue
+				}
+				size := 16
+				if i >= 6 {
+					size = 64
+				}
+				skipScalingList(br, size)
+			}
+		}
+	}
+
+	br.ue() // log2_max_frame_num_minus4
+	pocType, _ := br.ue()
+	switch pocType {
+	case 0:
+		br.ue() // log2_max_pic_order_cnt_lsb_minus4
+	case 1:
+		br.bit() // delta_pic_order_always_zero_flag
+		br.se()  // offset_for_non_ref_pic
+		br.se()  // offset_for_top_to_bottom_field
+
+		// num_ref_frames_in_pic_order_cnt_cycle
+		cycle, err := br.ue()
+		if err != nil {
+			return false, err
+		}
+		if cycle > maxPOCCycle {
+			return false, errors.New("h264: picture order count cycle is too long")
+		}
+		for i := uint(0); i < cycle; i++ {
+			_, err = br.se() // offset_for_ref_frame
+			if err != nil {
+				return false, err
+			}
+		}
+	}
+	br.ue()  // max_num_ref_frames
+	br.bit() // gaps_in_frame_num_value_allowed_flag
+	br.ue()  // pic_width_in_mbs_minus1
+	br.ue()  // pic_height_in_map_units_minus1
+	frameMbsOnly, err := br.bit()
+	return frameMbsOnly == 0, err
+}
+
+func skipScalingList(br *bitReader, size int) {
+	last, next := 8, 8
+	for i := 0; i < size; i++ {
+		if next != 0 {
+			delta, err := br.se()
+			if err != nil {
+				return
+			}
+			next = (last + delta + 256) % 256
+		}
+		if next != 0 {
+			last = next
+		}
+	}
+}
+
+// frame_mbs_only_flag from AVCDecoderConfigurationRecord (avcC box, MKV CodecPrivate),
+// see spsAllowsFields
+func avcConfigAllowsFields(config []byte) (bool, error) {
+	// version, profile, compatibility, level, NAL length size, SPS count
+	if len(config) < 8 || config[5]&0x1F == 0 {
+		return false, errShortSPS
+	}
+	length := int(config[6])<<8 | int(config[7])
+	if len(config) < 8+length {
+		return false, errShortSPS
+	}
+	return spsAllowsFields(config[8 : 8+length])
+}
@@ -0,0 +1,64 @@
+package container
+
+import (
+	"testing"
+)
+
+func TestSPSAllowsFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		sps    string
+		fields bool
+	}{
+		// High profile 720p from x264 (reisen example clip)
+		{"progressive", "6764001facb280a00b7420000003002003d09001e3064b", false},
+		// the same SPS coded as 1080i: frame_mbs_only_flag 0, MBAFF,
+		// picture order count type 0, 8 lines cropped at the bottom
+		{"mbaff", "67640028acd94078044fdc20000003002003d09001e3064b", true},
+		// Baseline profile with picture order count type 1 and a cycle of 2 frames
+		{"poc type 1", "6742c01ed364220280f640", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fields, err := spsAllowsFields(decodeHex(t, test.sps))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fields != test.fields {
+				t.Errorf("fields allowed: %v, want %v", fields, test.fields)
+			}
+		})
+	}
+}
+
+func TestSPSAllowsFieldsBroken(t *testing.T) {
+	tests := []struct {
+		name string
+		sps  string
+	}{
+		{"too short", "6764"},
+		{"truncated", "6764001facb280"},
+		// num_ref_frames_in_pic_order_cnt_cycle is 1000
+		{"long poc cycle", "6742c01ed3007d2421101407b2"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := spsAllowsFields(decodeHex(t, test.sps))
+			if err == nil {
+				t.Error("broken SPS is parsed without error")
+			}
+		})
+	}
+}
+
+func TestAVCConfigAllowsFields(t *testing.T) {
+	// avcC box of the reisen example clip
+	config := decodeHex(t, "0164001fffe100176764001facb280a00b7420000003002003d09001e3064b01000468e933cbfdf8f800")
+	fields, err := avcConfigAllowsFields(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fields {
+		t.Error("fields are allowed in progressive stream")
+	}
+}
@@ -0,0 +1,664 @@
+package container
+
+// Minimal reader of Matroska/WebM (EBML) element structure
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	ebmlHeaderID   = 0x1A45DFA3
+	segmentID      = 0x18538067
+	clusterID      = 0x1F43B675
+	tracksID       = 0x1654AE6B
+	seekHeadID     = 0x114D9B74
+	cuesID         = 0x1C53BB6B
+	chaptersID     = 0x1043A770
+	tagsID         = 0x1254C367
+	attachmentsID  = 0x1941A469
+	trackEntryID   = 0xAE
+	trackTypeID    = 0x83
+	codecID        = 0x86
+	codecPrivateID = 0x63A2
+	videoID        = 0xE0
+	colourID       = 0x55B0
+
+	flagInterlacedID = 0x9A
+	fieldOrderID     = 0x9D
+	projectionID     = 0x7670
+	projectionRollID = 0x7675
+
+	colourMatrixID    = 0x55B1
+	colourRangeID     = 0x55B9
+	colourTransferID  = 0x55BA
+	colourPrimariesID = 0x55BB
+
+	infoID             = 0x1549A966
+	timestampScaleID   = 0x2AD7B1
+	trackNumberID      = 0xD7
+	flagDefaultID      = 0x88
+	languageID         = 0x22B59C
+	nameID             = 0x536E
+	clusterTimestampID = 0xE7
+	simpleBlockID      = 0xA3
+	blockGroupID       = 0xA0
+	blockID            = 0xA1
+	blockDurationID    = 0x9B
+
+	contentEncodingsID     = 0x6D80
+	contentEncodingID      = 0x6240
+	contentEncodingScopeID = 0x5032
+	contentEncodingTypeID  = 0x5033
+	contentCompressionID   = 0x5034
+	contentCompAlgoID      = 0x4254
+	contentCompSettingsID  = 0x4255
+
+	trackTypeVideo    = 1
+	trackTypeSubtitle = 0x11
+
+	compressionNone            = -1 // ContentCompAlgo values
+	compressionZlib            = 0
+	compressionHeaderStripping = 3
+	scopePrivate               = 2 // ContentEncodingScope flag, codec private data is compressed
+
+	interlaced  = 1 // FlagInterlaced values
+	progressive = 2
+
+	unknownSize = -1
+)
+
+// Top level elements (children of Segment),
+// they end Segment children of unknown size (e.g. live recorded clusters)
+var topLevelIDs = map[uint32]bool{
+	seekHeadID:    true,
+	infoID:        true,
+	tracksID:      true,
+	cuesID:        true,
+	clusterID:     true,
+	chaptersID:    true,
+	tagsID:        true,
+	attachmentsID: true,
+}
+
+type element struct {
+	id    uint32
+	start int64 // offset of the data
+	end   int64 // offset right after the element, see unknownSizeEnd if size is unknown
+}
+
+// Reads variable size integer, returns its value and length.
+// keepMarker keeps length marker bit (used by element IDs)
+func readVint(r io.ReaderAt, offset int64, keepMarker bool) (int64, int, error) {
+	var first [1]byte
+	_, err := r.ReadAt(first[:], offset)
+	if err != nil {
+		return 0, 0, err
+	}
+	length := 1
+	for mask := byte(0x80); length <= 8 && first[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+	if length > 8 {
+		return 0, 0, fmt.Errorf("mkv: invalid variable size integer at %d", offset)
+	}
+
+	buf := make([]byte, length)
+	_, err = r.ReadAt(buf, offset)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !keepMarker {
+		buf[0] &= byte(0xFF >> length)
+	}
+	value := int64(0)
+	allOnes := true
+	for i, b := range buf {
+		value = value<<8 | int64(b)
+		if (i == 0 && b != byte(0xFF>>length)) || (i > 0 && b != 0xFF) {
+			allOnes = false
+		}
+	}
+	if !keepMarker && allOnes {
+		return unknownSize, length, nil
+	}
+	return value, length, nil
+}
+
+// Calls fn for every element in [start, end) range,
+// fn returning false stops the iteration
+func walkElements(r io.ReaderAt, start, end int64, fn func(e element) (bool, error)) error {
+	for offset := start; offset < end; {
+		id, idLength, err := readVint(r, offset, true)
+		if err != nil {
+			return err
+		}
+		size, sizeLength, err := readVint(r, offset+int64(idLength), false)
+		if err != nil {
+			return err
+		}
+		e := element{id: uint32(id), start: offset + int64(idLength+sizeLength)}
+		// header of the element crosses the end of the parent (truncated file)
+		if e.start > end {
+			return fmt.Errorf("mkv: element %X at %d is past the end of its parent", e.id, offset)
+		}
+		if size == unknownSize {
+			e.end, err = unknownSizeEnd(r, e, end)
+			if err != nil {
+				return err
+			}
+		} else {
+			e.end = e.start + size
+		}
+		if e.end > end {
+			e.end = end
+		}
+
+		more, err := fn(e)
+		if err != nil || !more {
+			return err
+		}
+		offset = e.end
+	}
+	return nil
+}
+
+// End of the element of unknown size: offset of the next top level element
+// (or of the next segment) found among its children.
+// Segment of unknown size lasts till the parent end,
+// so the whole file isn't scanned twice
+func unknownSizeEnd(r io.ReaderAt, e element, parentEnd int64) (int64, error) {
+	if e.id == segmentID {
+		return parentEnd, nil
+	}
+	for offset := e.start; offset < parentEnd; {
+		id, idLength, err := readVint(r, offset, true)
+		if err != nil {
+			return 0, err
+		}
+		if topLevelIDs[uint32(id)] || id == segmentID || id == ebmlHeaderID {
+			return offset, nil
+		}
+		size, sizeLength, err := readVint(r, offset+int64(idLength), false)
+		if err != nil {
+			return 0, err
+		}
+		if size == unknownSize {
+			break
+		}
+		offset += int64(idLength+sizeLength) + size
+	}
+	return parentEnd, nil
+}
+
+func readUint(r io.ReaderAt, e element) (uint64, error) {
+	length := e.end - e.start
+	if length < 0 || length > 8 {
+		return 0, fmt.Errorf("mkv: integer element %X has invalid size", e.id)
+	}
+	buf := make([]byte, 8)
+	_, err := r.ReadAt(buf[8-length:], e.start)
+	return binary.BigEndian.Uint64(buf), err
+}
+
+func readFloat(r io.ReaderAt, e element) (float64, error) {
+	data, err := readBytes(r, e)
+	if err != nil {
+		return 0, err
+	}
+	switch len(data) {
+	case 0:
+		return 0, nil
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data))), nil
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(data)), nil
+	}
+	return 0, fmt.Errorf("mkv: float element %X has invalid size", e.id)
+}
+
+func readBytes(r io.ReaderAt, e element) ([]byte, error) {
+	if e.end < e.start {
+		return nil, fmt.Errorf("mkv: element %X has invalid size", e.id)
+	}
+	data := make([]byte, e.end-e.start)
+	_, err := r.ReadAt(data, e.start)
+	return data, err
+}
+
+// Calls fn for every TrackEntry of the file
+func walkTrackEntries(r io.ReaderAt, size int64, fn func(track element) error) error {
+	return walkElements(r, 0, size, func(top element) (bool, error) {
+		if top.id != segmentID {
+			return true, nil
+		}
+		err := walkElements(r, top.start, top.end, func(e element) (bool, error) {
+			switch e.id {
+			case tracksID:
+				err := walkElements(r, e.start, e.end, func(track element) (bool, error) {
+					if track.id != trackEntryID {
+						return true, nil
+					}
+					return true, fn(track)
+				})
+				return false, err
+			case clusterID:
+				// tracks are expected before media data
+				return false, nil
+			}
+			return true, nil
+		})
+		return false, err
+	})
+}
+
+// Finds the first child element with the id
+func findElement(r io.ReaderAt, parent element, id uint32) (element, bool, error) {
+	var found element
+	ok := false
+	err := walkElements(r, parent.start, parent.end, func(e element) (bool, error) {
+		if e.id == id {
+			found = e
+			ok = true
+			return false, nil
+		}
+		return true, nil
+	})
+	return found, ok, err
+}
+
+func readChildUint(r io.ReaderAt, parent element, id uint32) (uint64, bool, error) {
+	e, ok, err := findElement(r, parent, id)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+	value, err := readUint(r, e)
+	return value, err == nil, err
+}
+
+func probeMKV(r io.ReaderAt, size int64, info *VideoInfo) error {
+	done := false
+	return walkTrackEntries(r, size, func(track element) error {
+		if done {
+			return nil
+		}
+		trackType, _, err := readChildUint(r, track, trackTypeID)
+		if err != nil || trackType != trackTypeVideo {
+			return err
+		}
+		done = true
+
+		video, ok, err := findElement(r, track, videoID)
+		if err != nil || !ok {
+			return err
+		}
+		err = readMKVRotation(r, video, info)
+		if err != nil {
+			return err
+		}
+		err = readMKVColour(r, video, info)
+		if err != nil {
+			return err
+		}
+		return readMKVFieldOrder(r, track, video, info)
+	})
+}
+
+// Rotation is stored as counter-clockwise projection roll
+func readMKVRotation(r io.ReaderAt, video element, info *VideoInfo) error {
+	projection, ok, err := findElement(r, video, projectionID)
+	if err != nil || !ok {
+		return err
+	}
+	roll, ok, err := findElement(r, projection, projectionRollID)
+	if err != nil || !ok {
+		return err
+	}
+	value, err := readFloat(r, roll)
+	if err != nil {
+		return err
+	}
+	info.Rotation = roundRotation(-value)
+	return nil
+}
+
+func readMKVColour(r io.ReaderAt, video element, info *VideoInfo) error {
+	colour, ok, err := findElement(r, video, colourID)
+	if err != nil || !ok {
+		return err
+	}
+	if value, ok, _ := readChildUint(r, colour, colourPrimariesID); ok {
+		info.ColorPrimaries = int(value)
+	}
+	if value, ok, _ := readChildUint(r, colour, colourTransferID); ok {
+		info.ColorTransfer = int(value)
+	}
+	if value, ok, _ := readChildUint(r, colour, colourMatrixID); ok {
+		info.ColorMatrix = int(value)
+	}
+	if value, ok, _ := readChildUint(r, colour, colourRangeID); ok {
+		info.FullRange = value == 2
+	}
+	return nil
+}
+
+// Reads interlacing flags, falls back to H.264 parameters
+// (which tell only whether fields are possible)
+func readMKVFieldOrder(r io.ReaderAt, track, video element, info *VideoInfo) error {
+	flag, ok, err := readChildUint(r, video, flagInterlacedID)
+	if err != nil {
+		return err
+	}
+	switch {
+	case ok && flag == progressive:
+		info.FieldOrder = Progressive
+		return nil
+	case ok && flag == interlaced:
+		info.FieldOrder = Interlaced
+		order, ok, err := readChildUint(r, video, fieldOrderID)
+		if err != nil || !ok {
+			return err
+		}
+		// 2 - undetermined
+		if order != 2 {
+			info.FieldOrder = fieldOrderFromCode(int(order))
+		}
+		return nil
+	}
+
+	codec, ok, err := findElement(r, track, codecID)
+	if err != nil || !ok {
+		return err
+	}
+	name, err := readBytes(r, codec)
+	if err != nil || string(name) != "V_MPEG4/ISO/AVC" {
+		return err
+	}
+	private, ok, err := findElement(r, track, codecPrivateID)
+	if err != nil || !ok {
+		return err
+	}
+	config, err := readBytes(r, private)
+	if err != nil {
+		return err
+	}
+	fields, err := avcConfigAllowsFields(config)
+	if err != nil {
+		// broken parameters shouldn't prevent playback
+		return nil
+	}
+	info.FieldOrder = Progressive
+	if fields {
+		info.FieldOrder = FieldsPossible
+	}
+	return nil
+}
+
+var mkvSubtitleCodecs = map[string]SubtitleCodec{
+	"S_TEXT/UTF8":   CodecSRT,
+	"S_TEXT/ASS":    CodecASS,
+	"S_TEXT/SSA":    CodecASS,
+	"S_ASS":         CodecASS,
+	"S_SSA":         CodecASS,
+	"S_TEXT/WEBVTT": CodecWebVTT,
+}
+
+type mkvSubtitleTrack struct {
+	*SubtitleTrack
+	compression int
+	stripped    []byte // prefix removed by header stripping
+}
+
+// Reads subtitle tracks and their blocks from all clusters.
+// Tracks are expected before media data,
+// clusters of unknown size end at the next top level element
+func readMKVSubtitles(r io.ReaderAt, size int64) ([]*SubtitleTrack, error) {
+	scale := int64(time.Millisecond) // nanoseconds per timestamp unit
+	tracks := make(map[int]*mkvSubtitleTrack)
+	var result []*SubtitleTrack
+	err := walkElements(r, 0, size, func(top element) (bool, error) {
+		if top.id != segmentID {
+			return true, nil
+		}
+		err := walkElements(r, top.start, top.end, func(e element) (bool, error) {
+			switch e.id {
+			case infoID:
+				if value, ok, _ := readChildUint(r, e, timestampScaleID); ok && value > 0 {
+					scale = int64(value)
+				}
+			case tracksID:
+				err := walkElements(r, e.start, e.end, func(entry element) (bool, error) {
+					if entry.id != trackEntryID {
+						return true, nil
+					}
+					track, err := readMKVSubtitleTrack(r, entry)
+					if err != nil || track == nil {
+						return err == nil, err
+					}
+					tracks[track.Number] = track
+					result = append(result, track.SubtitleTrack)
+					return true, nil
+				})
+				// there is no need to read the clusters without subtitle tracks
+				return err == nil && len(tracks) > 0, err
+			case clusterID:
+				return true, readMKVCluster(r, e, scale, tracks)
+			}
+			return true, nil
+		})
+		return false, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, track := range result {
+		sort.SliceStable(track.Samples, func(i, j int) bool {
+			return track.Samples[i].Start < track.Samples[j].Start
+		})
+		fillSampleEnds(track.Samples)
+	}
+	return result, nil
+}
+
+// Returns nil for tracks which are not text subtitles
+func readMKVSubtitleTrack(r io.ReaderAt, entry element) (*mkvSubtitleTrack, error) {
+	trackType, _, err := readChildUint(r, entry, trackTypeID)
+	if err != nil || trackType != trackTypeSubtitle {
+		return nil, err
+	}
+	codecName, _, err := readChildString(r, entry, codecID)
+	if err != nil {
+		return nil, err
+	}
+	// bitmap subtitles (PGS, VobSub) are not supported
+	codec, ok := mkvSubtitleCodecs[codecName]
+	if !ok {
+		return nil, nil
+	}
+	number, _, err := readChildUint(r, entry, trackNumberID)
+	if err != nil {
+		return nil, err
+	}
+	track := &mkvSubtitleTrack{
+		SubtitleTrack: &SubtitleTrack{Number: int(number), Codec: codec, Language: "eng", Default: true},
+		compression:   compressionNone,
+	}
+	if value, ok, _ := readChildUint(r, entry, flagDefaultID); ok {
+		track.Default = value == 1
+	}
+	if value, ok, _ := readChildString(r, entry, languageID); ok && value != "und" {
+		track.Language = value
+	}
+	if value, ok, _ := readChildString(r, entry, nameID); ok {
+		track.Title = value
+	}
+	if private, ok, err := findElement(r, entry, codecPrivateID); err != nil {
+		return nil, err
+	} else if ok {
+		track.Header, err = readBytes(r, private)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	encodings, ok, err := findElement(r, entry, contentEncodingsID)
+	if err != nil || !ok {
+		return track, err
+	}
+	supported, err := readMKVCompression(r, encodings, track)
+	if err != nil || !supported {
+		return nil, err
+	}
+	return track, nil
+}
+
+// Reads compression of the track blocks, encrypted tracks and
+// compression algorithms other than zlib and header stripping are not supported
+func readMKVCompression(r io.ReaderAt, encodings element, track *mkvSubtitleTrack) (bool, error) {
+	encoding, ok, err := findElement(r, encodings, contentEncodingID)
+	if err != nil || !ok {
+		return err == nil, err
+	}
+	if encodingType, ok, _ := readChildUint(r, encoding, contentEncodingTypeID); ok && encodingType != 0 {
+		return false, nil
+	}
+	compression, ok, err := findElement(r, encoding, contentCompressionID)
+	if err != nil || !ok {
+		return err == nil, err
+	}
+	algorithm, ok, err := readChildUint(r, compression, contentCompAlgoID)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		algorithm = compressionZlib
+	}
+	switch algorithm {
+	case compressionZlib:
+	case compressionHeaderStripping:
+		track.stripped, _, err = readChildBytes(r, compression, contentCompSettingsID)
+		if err != nil {
+			return false, err
+		}
+	default:
+		return false, nil
+	}
+	track.compression = int(algorithm)
+
+	if scope, ok, _ := readChildUint(r, encoding, contentEncodingScopeID); ok && scope&scopePrivate != 0 && track.Header != nil {
+		track.Header, err = track.decode(track.Header)
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (t *mkvSubtitleTrack) decode(data []byte) ([]byte, error) {
+	switch t.compression {
+	case compressionZlib:
+		z, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer z.Close()
+		return io.ReadAll(z)
+	case compressionHeaderStripping:
+		return append(append([]byte{}, t.stripped...), data...), nil
+	}
+	return data, nil
+}
+
+// Adds blocks of the subtitle tracks in the cluster to their samples
+func readMKVCluster(r io.ReaderAt, cluster element, scale int64, tracks map[int]*mkvSubtitleTrack) error {
+	var clusterTime int64
+	return walkElements(r, cluster.start, cluster.end, func(e element) (bool, error) {
+		switch e.id {
+		case clusterTimestampID:
+			value, err := readUint(r, e)
+			clusterTime = int64(value)
+			return true, err
+		case simpleBlockID:
+			return true, readMKVBlock(r, e, 0, clusterTime, scale, tracks)
+		case blockGroupID:
+			block, ok, err := findElement(r, e, blockID)
+			if err != nil || !ok {
+				return err == nil, err
+			}
+			duration, _, err := readChildUint(r, e, blockDurationID)
+			if err != nil {
+				return false, err
+			}
+			return true, readMKVBlock(r, block, int64(duration), clusterTime, scale, tracks)
+		}
+		return true, nil
+	})
+}
+
+// Reads the block if it belongs to one of the tracks, zero duration means unknown
+func readMKVBlock(
+	r io.ReaderAt,
+	block element,
+	duration, clusterTime, scale int64,
+	tracks map[int]*mkvSubtitleTrack,
+) error {
+	number, length, err := readVint(r, block.start, false)
+	if err != nil {
+		return err
+	}
+	track, ok := tracks[int(number)]
+	if !ok {
+		return nil
+	}
+	// relative timestamp and flags
+	header := make([]byte, 3)
+	offset := block.start + int64(length)
+	if offset+int64(len(header)) > block.end {
+		return fmt.Errorf("mkv: block at %d is too short", block.start)
+	}
+	_, err = r.ReadAt(header, offset)
+	if err != nil {
+		return err
+	}
+	// subtitles don't use lacing
+	if header[2]&0x06 != 0 {
+		return nil
+	}
+	data, err := readBytes(r, element{start: offset + int64(len(header)), end: block.end})
+	if err != nil {
+		return err
+	}
+	data, err = track.decode(data)
+	if err != nil {
+		return fmt.Errorf("mkv: track %d: %w", track.Number, err)
+	}
+
+	timestamp := clusterTime + int64(int16(binary.BigEndian.Uint16(header)))
+	sample := SubtitleSample{Start: time.Duration(timestamp * scale), Data: data}
+	if duration > 0 {
+		sample.End = time.Duration((timestamp + duration) * scale)
+	}
+	track.Samples = append(track.Samples, sample)
+	return nil
+}
+
+func readChildBytes(r io.ReaderAt, parent element, id uint32) ([]byte, bool, error) {
+	e, ok, err := findElement(r, parent, id)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	data, err := readBytes(r, e)
+	return data, err == nil, err
+}
+
+// Reads string element, strings may be padded with zeros
+func readChildString(r io.ReaderAt, parent element, id uint32) (string, bool, error) {
+	data, ok, err := readChildBytes(r, parent, id)
+	return string(bytes.TrimRight(data, "\x00")), ok, err
+}
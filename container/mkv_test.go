@@ -0,0 +1,165 @@
+package container
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Encodes EBML element ID (IDs keep their length marker)
+func ebmlID(id uint32) []byte {
+	switch {
+	case id >= 1<<24:
+		return []byte{byte(id >> 24), byte(id >> 16), byte(id >> 8), byte(id)}
+	case id >= 1<<16:
+		return []byte{byte(id >> 16), byte(id >> 8), byte(id)}
+	case id >= 1<<8:
+		return []byte{byte(id >> 8), byte(id)}
+	}
+	return []byte{byte(id)}
+}
+
+// Element with 5 bytes size
+func ebmlElement(id uint32, data ...[]byte) []byte {
+	body := bytes.Join(data, nil)
+	n := len(body)
+	out := append(ebmlID(id), 0x08, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	return append(out, body...)
+}
+
+// Element of unknown size
+func ebmlUnknownSize(id uint32, data ...[]byte) []byte {
+	out := append(ebmlID(id), 0xFF)
+	return append(out, bytes.Join(data, nil)...)
+}
+
+func mkvFile(segment []byte) []byte {
+	header := ebmlElement(ebmlHeaderID, []byte{0x42, 0x86, 0x81, 0x01})
+	return append(header, segment...)
+}
+
+func writeTestFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, data, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func decodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestProbeMKVFieldOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		video [][]byte
+		want  FieldOrder
+	}{
+		{"progressive", [][]byte{ebmlElement(flagInterlacedID, []byte{progressive})}, Progressive},
+		{"interlaced tff", [][]byte{
+			ebmlElement(flagInterlacedID, []byte{interlaced}),
+			ebmlElement(fieldOrderID, []byte{1}),
+		}, TopFieldFirst},
+		{"no flags", nil, FieldOrderUnknown},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			segment := ebmlElement(segmentID, ebmlElement(tracksID, ebmlElement(trackEntryID,
+				ebmlElement(trackNumberID, []byte{1}),
+				ebmlElement(trackTypeID, []byte{trackTypeVideo}),
+				ebmlElement(videoID, test.video...),
+			)))
+			info, err := Probe(writeTestFile(t, "a.mkv", mkvFile(segment)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.FieldOrder != test.want {
+				t.Errorf("field order %v, want %v", info.FieldOrder, test.want)
+			}
+		})
+	}
+}
+
+// Children of the truncated track entry end before they start
+func TestProbeMKVTruncated(t *testing.T) {
+	data := decodeHex(t, "1a45dfa38018538067881654ae6b8dae8b838101e0869a81019d81")
+	_, err := Probe(writeTestFile(t, "a.mkv", data))
+	if err == nil {
+		t.Error("truncated file is probed without error")
+	}
+}
+
+func subtitleBlock(track byte, timestamp int16, text string) []byte {
+	header := []byte{0x80 | track, byte(timestamp >> 8), byte(timestamp), 0x80}
+	return ebmlElement(simpleBlockID, header, []byte(text))
+}
+
+// Clusters of unknown size end at the next cluster or other top level element
+func TestReadMKVSubtitlesUnknownSizeClusters(t *testing.T) {
+	tracks := ebmlElement(tracksID, ebmlElement(trackEntryID,
+		ebmlElement(trackNumberID, []byte{2}),
+		ebmlElement(trackTypeID, []byte{trackTypeSubtitle}),
+		ebmlElement(codecID, []byte("S_TEXT/UTF8")),
+	))
+	segment := ebmlUnknownSize(segmentID,
+		tracks,
+		ebmlUnknownSize(clusterID,
+			ebmlElement(clusterTimestampID, []byte{0}),
+			subtitleBlock(1, 0, "video"),
+			subtitleBlock(2, 1000, "one"),
+		),
+		ebmlUnknownSize(clusterID,
+			ebmlElement(clusterTimestampID, []byte{0x13, 0x88}), // 5000
+			subtitleBlock(2, 0, "two"),
+		),
+		ebmlElement(clusterID,
+			ebmlElement(clusterTimestampID, []byte{0x27, 0x10}), // 10000
+			subtitleBlock(2, 0, "three"),
+		),
+		ebmlElement(cuesID),
+	)
+	got, err := ReadSubtitles(writeTestFile(t, "a.mkv", mkvFile(segment)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("%d tracks, want 1", len(got))
+	}
+	want := []struct {
+		start time.Duration
+		text  string
+	}{
+		{time.Second, "one"},
+		{5 * time.Second, "two"},
+		{10 * time.Second, "three"},
+	}
+	samples := got[0].Samples
+	if len(samples) != len(want) {
+		t.Fatalf("%d samples, want %d", len(samples), len(want))
+	}
+	for i, sample := range samples {
+		if sample.Start != want[i].start || string(sample.Data) != want[i].text {
+			t.Errorf("sample %d: %v %q, want %v %q", i, sample.Start, sample.Data, want[i].start, want[i].text)
+		}
+	}
+}
+
+// Track entry of the truncated file ends before its children start
+func TestReadMKVSubtitlesTruncated(t *testing.T) {
+	data := decodeHex(t, "1a45dfa380185380678b1654ae6b8aae888381118684535f544510fc")
+	_, err := ReadSubtitles(writeTestFile(t, "a.mkv", data))
+	if err == nil {
+		t.Error("truncated file is read without error")
+	}
+}
@@ -0,0 +1,558 @@
+package container
+
+// Minimal reader of ISO base media (MP4/MOV) box structure
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+	"unicode/utf16"
+)
+
+type box struct {
+	typ   string
+	start int64 // offset of the payload
+	end   int64 // offset right after the box
+}
+
+// Calls fn for every box in [start, end) range,
+// fn returning false stops the iteration
+func walkBoxes(r io.ReaderAt, start, end int64, fn func(b box) (bool, error)) error {
+	header := make([]byte, 16)
+	for offset := start; offset+8 <= end; {
+		_, err := r.ReadAt(header[:8], offset)
+		if err != nil {
+			return err
+		}
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		b := box{typ: string(header[4:8]), start: offset + 8}
+		switch size {
+		case 0: // box lasts till the end of the parent
+			b.end = end
+		case 1: // 64-bit size follows the type
+			_, err = r.ReadAt(header[8:16], offset+8)
+			if err != nil {
+				return err
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			b.start += 8
+			b.end = offset + size
+		default:
+			b.end = offset + size
+		}
+		if b.end < b.start || b.end > end {
+			return fmt.Errorf("mp4: box %q at %d has invalid size", b.typ, offset)
+		}
+
+		more, err := fn(b)
+		if err != nil || !more {
+			return err
+		}
+		offset = b.end
+	}
+	return nil
+}
+
+// Finds the first child box of the type
+func findBox(r io.ReaderAt, parent box, typ string) (box, bool, error) {
+	var found box
+	ok := false
+	err := walkBoxes(r, parent.start, parent.end, func(b box) (bool, error) {
+		if b.typ == typ {
+			found = b
+			ok = true
+			return false, nil
+		}
+		return true, nil
+	})
+	return found, ok, err
+}
+
+// Finds box by the path of types (e.g. "mdia", "minf", "stbl")
+func findPath(r io.ReaderAt, parent box, path ...string) (box, bool, error) {
+	current := parent
+	for _, typ := range path {
+		child, ok, err := findBox(r, current, typ)
+		if err != nil || !ok {
+			return box{}, false, err
+		}
+		current = child
+	}
+	return current, true, nil
+}
+
+func readPayload(r io.ReaderAt, b box) ([]byte, error) {
+	data := make([]byte, b.end-b.start)
+	_, err := r.ReadAt(data, b.start)
+	return data, err
+}
+
+// Handler type of the track ("vide", "soun", "sbtl", "text", ...)
+func trackHandler(r io.ReaderAt, trak box) (string, error) {
+	hdlr, ok, err := findPath(r, trak, "mdia", "hdlr")
+	if err != nil || !ok {
+		return "", err
+	}
+	data, err := readPayload(r, hdlr)
+	if err != nil {
+		return "", err
+	}
+	// version and flags, pre_defined, handler_type
+	if len(data) < 12 {
+		return "", fmt.Errorf("mp4: hdlr box is too short")
+	}
+	return string(data[8:12]), nil
+}
+
+// Calls fn for every track of the file
+func walkTracks(r io.ReaderAt, size int64, fn func(trak box, handler string) error) error {
+	return walkBoxes(r, 0, size, func(b box) (bool, error) {
+		if b.typ != "moov" {
+			return true, nil
+		}
+		err := walkBoxes(r, b.start, b.end, func(trak box) (bool, error) {
+			if trak.typ != "trak" {
+				return true, nil
+			}
+			handler, err := trackHandler(r, trak)
+			if err != nil {
+				return false, err
+			}
+			return true, fn(trak, handler)
+		})
+		return false, err
+	})
+}
+
+// First sample entry of the track, its type is the codec (avc1, tx3g, ...)
+func sampleEntry(r io.ReaderAt, trak box) (box, bool, error) {
+	stsd, ok, err := findPath(r, trak, "mdia", "minf", "stbl", "stsd")
+	if err != nil || !ok {
+		return box{}, false, err
+	}
+	// version and flags, entry_count
+	var entry box
+	found := false
+	err = walkBoxes(r, stsd.start+8, stsd.end, func(b box) (bool, error) {
+		entry = b
+		found = true
+		return false, nil
+	})
+	return entry, found, err
+}
+
+// Children of the first visual sample entry (avc1, hvc1, ...) of the track
+func visualSampleEntry(r io.ReaderAt, trak box) (box, bool, error) {
+	entry, ok, err := sampleEntry(r, trak)
+	if err != nil || !ok {
+		return box{}, false, err
+	}
+	// reserved, data_reference_index and fixed visual fields take 78 bytes
+	entry.start += 78
+	if entry.start > entry.end {
+		return box{}, false, fmt.Errorf("mp4: visual sample entry is too short")
+	}
+	return entry, true, nil
+}
+
+func probeMP4(r io.ReaderAt, size int64, info *VideoInfo) error {
+	done := false
+	return walkTracks(r, size, func(trak box, handler string) error {
+		if handler != "vide" || done {
+			return nil
+		}
+		done = true
+
+		err := readDisplayMatrix(r, trak, info)
+		if err != nil {
+			return err
+		}
+		entry, ok, err := visualSampleEntry(r, trak)
+		if err != nil || !ok {
+			return err
+		}
+		err = readColour(r, entry, info)
+		if err != nil {
+			return err
+		}
+		return readFieldOrder(r, entry, info)
+	})
+}
+
+// Reads rotation and mirroring from the transformation matrix of the track header
+func readDisplayMatrix(r io.ReaderAt, trak box, info *VideoInfo) error {
+	tkhd, ok, err := findBox(r, trak, "tkhd")
+	if err != nil || !ok {
+		return err
+	}
+	data, err := readPayload(r, tkhd)
+	if err != nil || len(data) < 1 {
+		return err
+	}
+	// version 1 has 64-bit creation, modification time and duration
+	offset := 40
+	if data[0] == 1 {
+		offset = 52
+	}
+	if len(data) < offset+36 {
+		return fmt.Errorf("mp4: tkhd box is too short")
+	}
+	// matrix is {a, b, u, c, d, v, x, y, w}, a-d are 16.16 fixed point numbers
+	fixed := func(i int) float64 {
+		return float64(int32(binary.BigEndian.Uint32(data[offset+i*4:]))) / (1 << 16)
+	}
+	a, b, c, d := fixed(0), fixed(1), fixed(3), fixed(4)
+	mirrored := a*d-b*c < 0
+	if mirrored {
+		// matrix is rotation followed by horizontal flip,
+		// flip negates x of the transformed axes
+		a = -a
+	}
+	info.Rotation = roundRotation(math.Atan2(b, a) * 180 / math.Pi)
+	info.Mirrored = mirrored
+	return nil
+}
+
+// Reads nclx colour description of the sample entry
+func readColour(r io.ReaderAt, entry box, info *VideoInfo) error {
+	colr, ok, err := findBox(r, entry, "colr")
+	if err != nil || !ok {
+		return err
+	}
+	data, err := readPayload(r, colr)
+	if err != nil {
+		return err
+	}
+	if len(data) >= 11 && string(data[:4]) == "nclx" {
+		info.ColorPrimaries = int(binary.BigEndian.Uint16(data[4:6]))
+		info.ColorTransfer = int(binary.BigEndian.Uint16(data[6:8]))
+		info.ColorMatrix = int(binary.BigEndian.Uint16(data[8:10]))
+		info.FullRange = data[10]&0x80 != 0
+	}
+	return nil
+}
+
+// Reads field order from fiel box, falls back to H.264 parameters
+// (which tell only whether fields are possible)
+func readFieldOrder(r io.ReaderAt, entry box, info *VideoInfo) error {
+	fiel, ok, err := findBox(r, entry, "fiel")
+	if err != nil {
+		return err
+	}
+	if ok {
+		data, err := readPayload(r, fiel)
+		if err != nil || len(data) < 2 {
+			return err
+		}
+		info.FieldOrder = Progressive
+		if data[0] == 2 {
+			info.FieldOrder = fieldOrderFromCode(int(data[1]))
+		}
+		return nil
+	}
+
+	avcC, ok, err := findBox(r, entry, "avcC")
+	if err != nil || !ok {
+		return err
+	}
+	config, err := readPayload(r, avcC)
+	if err != nil {
+		return err
+	}
+	fields, err := avcConfigAllowsFields(config)
+	if err != nil {
+		// broken parameters shouldn't prevent playback
+		return nil
+	}
+	info.FieldOrder = Progressive
+	if fields {
+		info.FieldOrder = FieldsPossible
+	}
+	return nil
+}
+
+// Limits protecting from broken subtitle sample tables
+const (
+	maxSamples    = 1 << 20
+	maxSampleSize = 1 << 20
+)
+
+type mp4Sample struct {
+	offset   int64
+	size     int64
+	start    uint64 // in the track timescale
+	duration uint64
+}
+
+// Reads timed text (tx3g) tracks, edit lists are ignored
+func readMP4Subtitles(r io.ReaderAt, size int64) ([]*SubtitleTrack, error) {
+	// QuickTime chapters are text tracks as well
+	chapters := make(map[int]bool)
+	err := walkTracks(r, size, func(trak box, handler string) error {
+		chap, ok, err := findPath(r, trak, "tref", "chap")
+		if err != nil || !ok {
+			return err
+		}
+		data, err := readPayload(r, chap)
+		for i := 0; i+4 <= len(data); i += 4 {
+			chapters[int(binary.BigEndian.Uint32(data[i:]))] = true
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var tracks []*SubtitleTrack
+	err = walkTracks(r, size, func(trak box, handler string) error {
+		if handler != "sbtl" && handler != "text" {
+			return nil
+		}
+		entry, ok, err := sampleEntry(r, trak)
+		if err != nil || !ok || (entry.typ != "tx3g" && entry.typ != "text") {
+			return err
+		}
+		track := &SubtitleTrack{Codec: CodecMovText}
+		err = readTrackHeader(r, trak, track)
+		if err != nil || chapters[track.Number] {
+			return err
+		}
+		timescale, err := readMediaHeader(r, trak, track)
+		if err != nil {
+			return err
+		}
+		stbl, ok, err := findPath(r, trak, "mdia", "minf", "stbl")
+		if err != nil || !ok {
+			return err
+		}
+		samples, err := readSampleTable(r, stbl)
+		if err != nil {
+			return err
+		}
+
+		toDuration := func(t uint64) time.Duration {
+			return time.Duration(float64(t) / float64(timescale) * float64(time.Second))
+		}
+		for _, sample := range samples {
+			if sample.size > maxSampleSize {
+				return fmt.Errorf("mp4: subtitle sample at %d is too large", sample.offset)
+			}
+			data := make([]byte, sample.size)
+			_, err := r.ReadAt(data, sample.offset)
+			if err != nil {
+				return err
+			}
+			// empty samples are gaps between subtitles
+			text := movText(data)
+			if text == "" {
+				continue
+			}
+			track.Samples = append(track.Samples, SubtitleSample{
+				Start: toDuration(sample.start),
+				End:   toDuration(sample.start + sample.duration),
+				Data:  []byte(text),
+			})
+		}
+		fillSampleEnds(track.Samples)
+		tracks = append(tracks, track)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tracks, nil
+}
+
+// Reads track ID and enabled flag (set for the default track)
+func readTrackHeader(r io.ReaderAt, trak box, track *SubtitleTrack) error {
+	tkhd, ok, err := findBox(r, trak, "tkhd")
+	if err != nil || !ok {
+		return err
+	}
+	data, err := readPayload(r, tkhd)
+	if err != nil {
+		return err
+	}
+	// version 1 has 64-bit creation and modification time
+	offset := 12
+	if len(data) > 0 && data[0] == 1 {
+		offset = 20
+	}
+	if len(data) < offset+4 {
+		return fmt.Errorf("mp4: tkhd box is too short")
+	}
+	track.Default = data[3]&1 != 0
+	track.Number = int(binary.BigEndian.Uint32(data[offset:]))
+	return nil
+}
+
+// Reads language of the track, returns its timescale
+func readMediaHeader(r io.ReaderAt, trak box, track *SubtitleTrack) (uint32, error) {
+	mdhd, ok, err := findPath(r, trak, "mdia", "mdhd")
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("mp4: mdhd box is missing")
+	}
+	data, err := readPayload(r, mdhd)
+	if err != nil {
+		return 0, err
+	}
+	// version 1 has 64-bit creation, modification time and duration
+	timescaleOffset, languageOffset := 12, 20
+	if len(data) > 0 && data[0] == 1 {
+		timescaleOffset, languageOffset = 20, 32
+	}
+	if len(data) < languageOffset+2 {
+		return 0, fmt.Errorf("mp4: mdhd box is too short")
+	}
+	timescale := binary.BigEndian.Uint32(data[timescaleOffset:])
+	if timescale == 0 {
+		return 0, fmt.Errorf("mp4: track timescale is zero")
+	}
+	// ISO 639-2 code packed as three 5-bit letters
+	code := binary.BigEndian.Uint16(data[languageOffset:])
+	language := string([]byte{byte(code>>10&0x1F) + 0x60, byte(code>>5&0x1F) + 0x60, byte(code&0x1F) + 0x60})
+	if code != 0 && language != "und" {
+		track.Language = language
+	}
+	return timescale, nil
+}
+
+// Locates samples of the track by its sample table
+func readSampleTable(r io.ReaderAt, stbl box) ([]mp4Sample, error) {
+	table := func(typ string, entriesOffset, entrySize int) ([]byte, int, error) {
+		b, ok, err := findBox(r, stbl, typ)
+		if err != nil || !ok {
+			return nil, 0, err
+		}
+		data, err := readPayload(r, b)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(data) < entriesOffset {
+			return nil, 0, fmt.Errorf("mp4: %s box is too short", typ)
+		}
+		count := int(binary.BigEndian.Uint32(data[entriesOffset-4:]))
+		if entrySize > 0 && count > (len(data)-entriesOffset)/entrySize {
+			return nil, 0, fmt.Errorf("mp4: %s box is too short", typ)
+		}
+		return data[entriesOffset:], count, nil
+	}
+
+	stts, timeEntries, err := table("stts", 8, 8)
+	if err != nil {
+		return nil, err
+	}
+	var samples []mp4Sample
+	var start uint64
+	for i := 0; i < timeEntries; i++ {
+		count := binary.BigEndian.Uint32(stts[i*8:])
+		delta := uint64(binary.BigEndian.Uint32(stts[i*8+4:]))
+		for j := uint32(0); j < count && len(samples) < maxSamples; j++ {
+			samples = append(samples, mp4Sample{start: start, duration: delta})
+			start += delta
+		}
+	}
+
+	// sample size is either the same for all samples or follows the count
+	stsz, ok, err := findBox(r, stbl, "stsz")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("mp4: stsz box is missing")
+	}
+	sizeData, err := readPayload(r, stsz)
+	if err != nil {
+		return nil, err
+	}
+	if len(sizeData) < 12 {
+		return nil, fmt.Errorf("mp4: stsz box is too short")
+	}
+	if size := binary.BigEndian.Uint32(sizeData[4:]); size != 0 {
+		for i := range samples {
+			samples[i].size = int64(size)
+		}
+	} else {
+		sizes, count, err := table("stsz", 12, 4)
+		if err != nil {
+			return nil, err
+		}
+		if count < len(samples) {
+			samples = samples[:count]
+		}
+		for i := range samples {
+			samples[i].size = int64(binary.BigEndian.Uint32(sizes[i*4:]))
+		}
+	}
+
+	offsets, chunks, err := table("stco", 8, 4)
+	if err != nil {
+		return nil, err
+	}
+	offsetSize := 4
+	if offsets == nil {
+		offsets, chunks, err = table("co64", 8, 8)
+		if err != nil {
+			return nil, err
+		}
+		offsetSize = 8
+	}
+	chunkOffset := func(i int) int64 {
+		if offsetSize == 8 {
+			return int64(binary.BigEndian.Uint64(offsets[i*8:]))
+		}
+		return int64(binary.BigEndian.Uint32(offsets[i*4:]))
+	}
+	stsc, chunkEntries, err := table("stsc", 8, 12)
+	if err != nil {
+		return nil, err
+	}
+
+	// stsc entries are runs of chunks with the same number of samples
+	sample := 0
+	for i := 0; i < chunkEntries && sample < len(samples); i++ {
+		firstChunk := int(binary.BigEndian.Uint32(stsc[i*12:])) - 1
+		perChunk := int(binary.BigEndian.Uint32(stsc[i*12+4:]))
+		lastChunk := chunks
+		if i+1 < chunkEntries {
+			lastChunk = int(binary.BigEndian.Uint32(stsc[(i+1)*12:])) - 1
+		}
+		if firstChunk < 0 || lastChunk > chunks {
+			return nil, fmt.Errorf("mp4: invalid stsc box")
+		}
+		for chunk := firstChunk; chunk < lastChunk && sample < len(samples); chunk++ {
+			offset := chunkOffset(chunk)
+			for j := 0; j < perChunk && sample < len(samples); j++ {
+				samples[sample].offset = offset
+				offset += samples[sample].size
+				sample++
+			}
+		}
+	}
+	return samples[:sample], nil
+}
+
+// Text of tx3g sample: 16-bit length, UTF-8 or UTF-16 text and style boxes (ignored)
+func movText(data []byte) string {
+	if len(data) < 2 {
+		return ""
+	}
+	length := int(binary.BigEndian.Uint16(data))
+	if length > len(data)-2 {
+		length = len(data) - 2
+	}
+	text := data[2 : 2+length]
+	if len(text) >= 2 && text[0] == 0xFE && text[1] == 0xFF {
+		units := make([]uint16, 0, len(text)/2-1)
+		for i := 2; i+1 < len(text); i += 2 {
+			units = append(units, binary.BigEndian.Uint16(text[i:]))
+		}
+		return string(utf16.Decode(units))
+	}
+	return string(text)
+}
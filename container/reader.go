@@ -0,0 +1,41 @@
+package container
+
+import (
+	"io"
+)
+
+// Size of the window cached by bufferedReader: enough for headers of several elements
+// and for a whole subtitle block
+const readerWindow = 4096
+
+// Caches the last read window of the file, so walking elements doesn't
+// make several small reads for every element header.
+// Reads bigger than the window go to the file directly
+type bufferedReader struct {
+	r      io.ReaderAt
+	buf    []byte
+	offset int64 // file offset of buf
+}
+
+func newBufferedReader(r io.ReaderAt) *bufferedReader {
+	return &bufferedReader{r: r, buf: make([]byte, 0, readerWindow)}
+}
+
+func (br *bufferedReader) ReadAt(p []byte, offset int64) (int, error) {
+	if len(p) > cap(br.buf) || offset < 0 {
+		return br.r.ReadAt(p, offset)
+	}
+	if offset < br.offset || offset+int64(len(p)) > br.offset+int64(len(br.buf)) {
+		n, err := br.r.ReadAt(br.buf[:cap(br.buf)], offset)
+		br.buf, br.offset = br.buf[:n], offset
+		// the window may be cut by the end of the file
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+	}
+	n := copy(p, br.buf[offset-br.offset:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
@@ -0,0 +1,73 @@
+package container
+
+// Extraction of text subtitle tracks, the decoder doesn't support subtitle streams
+
+import (
+	"os"
+	"time"
+)
+
+// Samples without duration are shown until the next one but not longer than this
+const maxSampleDuration = 10 * time.Second
+
+type SubtitleCodec int
+
+const (
+	CodecSRT     SubtitleCodec = iota // plain text with HTML-like tags (Matroska S_TEXT/UTF8)
+	CodecASS                          // ASS/SSA events without timing, script header is in Header
+	CodecWebVTT                       // WebVTT cue text
+	CodecMovText                      // MP4 timed text (tx3g), samples are already decoded to plain text
+)
+
+type SubtitleSample struct {
+	Start time.Duration
+	End   time.Duration // zero if the container doesn't store duration
+	Data  []byte
+}
+
+type SubtitleTrack struct {
+	Number   int // track number in the container
+	Codec    SubtitleCodec
+	Language string // ISO 639-2 code, empty if unknown
+	Title    string
+	Default  bool   // track is marked to be shown by default
+	Header   []byte // codec private data (ASS script header)
+	Samples  []SubtitleSample
+}
+
+// Reads text subtitle tracks of the file.
+// Returns no tracks for unsupported containers
+func ReadSubtitles(path string) ([]*SubtitleTrack, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	switch detectFormat(f) {
+	case formatMP4:
+		return readMP4Subtitles(f, stat.Size())
+	case formatMKV:
+		// clusters are walked block by block
+		return readMKVSubtitles(newBufferedReader(f), stat.Size())
+	}
+	return nil, nil
+}
+
+// Sets missing end times, samples have to be sorted by start time
+func fillSampleEnds(samples []SubtitleSample) {
+	for i := range samples {
+		if samples[i].End > samples[i].Start {
+			continue
+		}
+		end := samples[i].Start + maxSampleDuration
+		if i+1 < len(samples) && samples[i+1].Start > samples[i].Start && samples[i+1].Start < end {
+			end = samples[i+1].Start
+		}
+		samples[i].End = end
+	}
+}
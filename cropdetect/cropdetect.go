@@ -0,0 +1,230 @@
+package cropdetect
+
+// Detection of black bars burned into the video.
+// Content rectangle is measured on frames every analysisInterval,
+// measurements of the last window are merged (dark scenes don't shrink the crop),
+// and the crop follows them with hysteresis: it grows at once (no content is cut),
+// but shrinks only after the new rectangle stays stable for holdTime
+
+import (
+	"image"
+	"time"
+	"videoplayer/multithread"
+)
+
+const (
+	analysisInterval = 100 * time.Millisecond
+	window           = 3 * time.Second
+	holdTime         = 2 * time.Second
+	blackLevel       = 32   // max luma of black bars (0-255)
+	brightShare      = 0.05 // share of bright samples making the line content
+	sampleStep       = 4    // distance between samples of a line in pixels
+	minChange        = 4    // smaller changes of edges (in pixels) are ignored
+	minBar           = 0.01 // bars thinner than this share of frame size are not cropped
+)
+
+type measurement struct {
+	rect image.Rectangle
+	pts  time.Duration
+}
+
+type Detector struct {
+	measurements []measurement
+	lastPts      time.Duration
+	analyzed     bool // at least one frame was analyzed
+	frameSize    image.Point
+	crop         image.Rectangle // stable crop, empty - no crop
+	candidate    image.Rectangle // smaller crop waiting for holdTime
+	candidateAt  time.Duration
+}
+
+func New() *Detector {
+	return &Detector{}
+}
+
+// Stable crop rectangle in frame pixels, empty if there is nothing to crop
+func (d *Detector) Crop() image.Rectangle {
+	return d.crop
+}
+
+// Forgets measurements and crop
+func (d *Detector) Reset() {
+	*d = Detector{}
+}
+
+// Measures frame if it's time to do so, returns true if crop has changed
+func (d *Detector) Analyze(frame *multithread.Frame) bool {
+	if d.analyzed && frame.Pts >= d.lastPts && frame.Pts-d.lastPts < analysisInterval {
+		return false
+	}
+	if frame.Pts < d.lastPts {
+		// rewind, old measurements belong to other part of the video
+		d.measurements = d.measurements[:0]
+		d.candidate = image.Rectangle{}
+	}
+	d.analyzed = true
+	d.lastPts = frame.Pts
+
+	if frame.Image == nil {
+		return false
+	}
+	var l luma = rgbaLuma{frame.Image}
+	size := l.bounds().Size()
+	if size != d.frameSize {
+		// resolution change makes everything measured before invalid
+		d.Reset()
+		d.analyzed = true
+		d.lastPts = frame.Pts
+		d.frameSize = size
+	}
+
+	rect, ok := contentRect(l)
+	if ok {
+		d.measurements = append(d.measurements, measurement{rect, frame.Pts})
+	}
+	for len(d.measurements) > 0 && frame.Pts-d.measurements[0].pts > window {
+		d.measurements = d.measurements[1:]
+	}
+	if len(d.measurements) == 0 {
+		return false
+	}
+
+	merged := d.measurements[0].rect
+	for _, m := range d.measurements[1:] {
+		merged = merged.Union(m.rect)
+	}
+	return d.update(d.normalize(merged), frame.Pts)
+}
+
+// Whole frame if bars are too thin, even coordinates otherwise
+func (d *Detector) normalize(rect image.Rectangle) image.Rectangle {
+	frame := image.Rectangle{Max: d.frameSize}
+	minX := int(float64(d.frameSize.X) * minBar)
+	minY := int(float64(d.frameSize.Y) * minBar)
+	if rect.Min.X < minX && frame.Max.X-rect.Max.X < minX {
+		rect.Min.X, rect.Max.X = 0, frame.Max.X
+	}
+	if rect.Min.Y < minY && frame.Max.Y-rect.Max.Y < minY {
+		rect.Min.Y, rect.Max.Y = 0, frame.Max.Y
+	}
+	rect.Min.X += rect.Min.X % 2
+	rect.Min.Y += rect.Min.Y % 2
+	rect.Max.X -= rect.Max.X % 2
+	rect.Max.Y -= rect.Max.Y % 2
+	if rect == frame {
+		return image.Rectangle{}
+	}
+	return rect
+}
+
+func (d *Detector) update(rect image.Rectangle, pts time.Duration) bool {
+	current := d.crop
+	if current.Empty() {
+		current = image.Rectangle{Max: d.frameSize}
+	}
+	target := rect
+	if target.Empty() {
+		target = image.Rectangle{Max: d.frameSize}
+	}
+	if similar(current, target) {
+		d.candidate = image.Rectangle{}
+		return false
+	}
+
+	// content outside of the crop is shown right away
+	if !target.In(current) {
+		d.crop = rect
+		d.candidate = image.Rectangle{}
+		return true
+	}
+
+	if !similar(d.candidate, target) {
+		d.candidate = target
+		d.candidateAt = pts
+		return false
+	}
+	if pts-d.candidateAt < holdTime {
+		return false
+	}
+	d.crop = rect
+	d.candidate = image.Rectangle{}
+	return true
+}
+
+func similar(a, b image.Rectangle) bool {
+	return abs(a.Min.X-b.Min.X) <= minChange && abs(a.Min.Y-b.Min.Y) <= minChange &&
+		abs(a.Max.X-b.Max.X) <= minChange && abs(a.Max.Y-b.Max.Y) <= minChange
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// Luma access to frames of different formats
+type luma interface {
+	bounds() image.Rectangle
+	at(x, y int) uint8
+}
+
+type rgbaLuma struct {
+	img *image.RGBA
+}
+
+func (l rgbaLuma) bounds() image.Rectangle {
+	return l.img.Rect
+}
+
+func (l rgbaLuma) at(x, y int) uint8 {
+	i := l.img.PixOffset(x, y)
+	pix := l.img.Pix[i : i+3 : i+3]
+	// BT.601 luma in fixed point
+	return uint8((77*int(pix[0]) + 150*int(pix[1]) + 29*int(pix[2])) >> 8)
+}
+
+// Bounds of the non black part of the frame, false for entirely black frames
+func contentRect(l luma) (image.Rectangle, bool) {
+	b := l.bounds()
+	rowBright := func(y int) bool {
+		return isBright(l, b.Min.X, b.Max.X, func(i int) (int, int) { return i, y })
+	}
+	columnBright := func(x int) bool {
+		return isBright(l, b.Min.Y, b.Max.Y, func(i int) (int, int) { return x, i })
+	}
+
+	top := b.Min.Y
+	for top < b.Max.Y && !rowBright(top) {
+		top++
+	}
+	if top == b.Max.Y {
+		return image.Rectangle{}, false
+	}
+	bottom := b.Max.Y
+	for bottom > top && !rowBright(bottom-1) {
+		bottom--
+	}
+	left := b.Min.X
+	for left < b.Max.X && !columnBright(left) {
+		left++
+	}
+	right := b.Max.X
+	for right > left && !columnBright(right-1) {
+		right--
+	}
+	return image.Rect(left, top, right, bottom).Sub(b.Min), true
+}
+
+// Reports whether enough samples of the line are brighter than black level,
+// point maps position along the line to pixel coordinates
+func isBright(l luma, from, to int, point func(i int) (int, int)) bool {
+	samples, bright := 0, 0
+	for i := from; i < to; i += sampleStep {
+		samples++
+		if l.at(point(i)) > blackLevel {
+			bright++
+		}
+	}
+	return samples > 0 && float64(bright) >= float64(samples)*brightShare
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// max interval between clicks of a double click
+const doubleClickInterval = 400 * time.Millisecond
+
+// Switching between windowed and fullscreen mode,
+// windowed geometry is restored on exit from fullscreen
+type Fullscreen struct {
+	monitorIndex  int
+	active        bool
+	x, y          int // windowed position and size
+	width, height int
+}
+
+// Monitor fullscreen mode uses, index is the one of glfw.GetMonitors (0 - primary)
+func (f *Fullscreen) Monitor() (*glfw.Monitor, error) {
+	monitors := glfw.GetMonitors()
+	if f.monitorIndex < 0 || f.monitorIndex >= len(monitors) {
+		return nil, fmt.Errorf("monitor %v is not found (%v connected)", f.monitorIndex, len(monitors))
+	}
+	return monitors[f.monitorIndex], nil
+}
+
+// Monitor showing the window: the fullscreen one or the monitor containing
+// the center of the window, primary monitor if there is no such
+func (f *Fullscreen) WindowMonitor(window *glfw.Window) *glfw.Monitor {
+	if f.active {
+		if monitor, err := f.Monitor(); err == nil {
+			return monitor
+		}
+	}
+	x, y := window.GetPos()
+	width, height := window.GetSize()
+	centerX, centerY := x+width/2, y+height/2
+	for _, monitor := range glfw.GetMonitors() {
+		mx, my := monitor.GetPos()
+		mode := monitor.GetVideoMode()
+		if centerX >= mx && centerX < mx+mode.Width && centerY >= my && centerY < my+mode.Height {
+			return monitor
+		}
+	}
+	return glfw.GetPrimaryMonitor()
+}
+
+func (f *Fullscreen) Active() bool {
+	return f.active
+}
+
+func (f *Fullscreen) Toggle(window *glfw.Window) error {
+	if f.active {
+		f.Exit(window)
+		return nil
+	}
+	return f.Enter(window)
+}
+
+// Switches window to the monitor at its native video mode
+func (f *Fullscreen) Enter(window *glfw.Window) error {
+	if f.active {
+		return nil
+	}
+	monitor, err := f.Monitor()
+	if err != nil {
+		return err
+	}
+	f.x, f.y = window.GetPos()
+	f.width, f.height = window.GetSize()
+	mode := monitor.GetVideoMode()
+	window.SetMonitor(monitor, 0, 0, mode.Width, mode.Height, mode.RefreshRate)
+	f.active = true
+	return nil
+}
+
+func (f *Fullscreen) Exit(window *glfw.Window) {
+	if !f.active {
+		return
+	}
+	window.SetMonitor(nil, f.x, f.y, f.width, f.height, glfw.DontCare)
+	f.active = false
+}
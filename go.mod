@@ -3,15 +3,19 @@ module videoplayer
 go 1.18
 
 require (
-	github.com/faiface/beep v1.1.0 // indirect
-	github.com/go-gl/gl v0.0.0-20211210172815-726fda9656d6 // indirect
-	github.com/go-gl/glfw/v3.3/glfw v0.0.0-20220806181222-55e207c401ad // indirect
-	github.com/go-gl/mathgl v1.0.0 // indirect
+	github.com/faiface/beep v1.1.0
+	github.com/go-gl/gl v0.0.0-20211210172815-726fda9656d6
+	github.com/go-gl/glfw/v3.3/glfw v0.0.0-20220806181222-55e207c401ad
+	github.com/go-gl/mathgl v1.0.0
+	github.com/zergon321/reisen v0.1.8
+	golang.org/x/image v0.18.0
+	golang.org/x/text v0.16.0
+)
+
+require (
 	github.com/hajimehoshi/oto v0.7.1 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
-	github.com/zergon321/reisen v0.1.4 // indirect
 	golang.org/x/exp v0.0.0-20190731235908-ec7cb31e5a56 // indirect
-	golang.org/x/image v0.0.0-20200801110659-972c09e46d76 // indirect
 	golang.org/x/mobile v0.0.0-20210208171126-f462b3930c8f // indirect
 	golang.org/x/sys v0.0.0-20200918174421-af09f7315aff // indirect
 )
@@ -0,0 +1,180 @@
+package loaders
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Colour lookup table in Adobe/Resolve .cube format
+type CubeLUT struct {
+	Title     string
+	Is3D      bool
+	Size      int
+	DomainMin [3]float32
+	DomainMax [3]float32
+	// RGB triples, for 3D tables red index changes fastest, then green, then blue
+	Data []float32
+	// 1D table applied to the inputs of the 3D one, nil if the file has no shaper
+	Shaper *CubeShaper
+}
+
+// 1D shaper of Resolve files, its output is the input of the 3D table
+type CubeShaper struct {
+	Size      int
+	DomainMin [3]float32
+	DomainMax [3]float32
+	Data      []float32 // RGB triples
+}
+
+func LoadCube(path string) (*CubeLUT, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	lut, err := ParseCube(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return lut, nil
+}
+
+// Table of the file, Resolve files may have 1D shaper table followed by 3D one
+type cubeTable struct {
+	size      int
+	domainMin [3]float32
+	domainMax [3]float32
+}
+
+// Parses 1D or 3D table. The 1D table of the file with both tables is kept
+// as the shaper of the 3D one
+func ParseCube(r io.Reader) (*CubeLUT, error) {
+	lut := &CubeLUT{}
+	var tables [2]cubeTable // 1D and 3D
+	for i := range tables {
+		tables[i].domainMax = [3]float32{1, 1, 1}
+	}
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		keyword := fields[0]
+
+		var err error
+		switch keyword {
+		case "TITLE":
+			lut.Title = strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "TITLE")), `"`)
+		case "LUT_1D_SIZE", "LUT_3D_SIZE":
+			table := &tables[0]
+			is3D := keyword == "LUT_3D_SIZE"
+			if is3D {
+				table = &tables[1]
+			}
+			if table.size != 0 {
+				return nil, fmt.Errorf("line %d: table size is specified twice", lineNumber)
+			}
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("line %d: %s expects one value", lineNumber, keyword)
+			}
+			table.size, err = strconv.Atoi(fields[1])
+			if err == nil && (table.size < 2 || (is3D && table.size > 256) || table.size > 65536) {
+				err = fmt.Errorf("unsupported table size %d", table.size)
+			}
+		case "DOMAIN_MIN":
+			// Adobe files have one table
+			err = parseValues(fields[1:], tables[0].domainMin[:])
+			tables[1].domainMin = tables[0].domainMin
+		case "DOMAIN_MAX":
+			err = parseValues(fields[1:], tables[0].domainMax[:])
+			tables[1].domainMax = tables[0].domainMax
+		case "LUT_1D_INPUT_RANGE", "LUT_3D_INPUT_RANGE":
+			// Resolve flavour of the domain
+			table := &tables[0]
+			if keyword == "LUT_3D_INPUT_RANGE" {
+				table = &tables[1]
+			}
+			var inputRange [2]float32
+			err = parseValues(fields[1:], inputRange[:])
+			table.domainMin = [3]float32{inputRange[0], inputRange[0], inputRange[0]}
+			table.domainMax = [3]float32{inputRange[1], inputRange[1], inputRange[1]}
+		default:
+			if !isNumber(keyword) {
+				// unknown keywords are allowed by the specification
+				continue
+			}
+			var rgb [3]float32
+			err = parseValues(fields, rgb[:])
+			lut.Data = append(lut.Data, rgb[:]...)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	shaper, table := tables[0], tables[1]
+	if shaper.size == 0 && table.size == 0 {
+		return nil, fmt.Errorf("table size is not specified")
+	}
+	entries := shaper.size + table.size*table.size*table.size
+	if len(lut.Data) != 3*entries {
+		return nil, fmt.Errorf("expected %d table entries, got %d", entries, len(lut.Data)/3)
+	}
+	for _, t := range tables {
+		for i := 0; i < 3; i++ {
+			if t.domainMin[i] >= t.domainMax[i] {
+				return nil, fmt.Errorf("domain min must be less than domain max")
+			}
+		}
+	}
+
+	switch {
+	case table.size == 0:
+		lut.Size, lut.DomainMin, lut.DomainMax = shaper.size, shaper.domainMin, shaper.domainMax
+	case shaper.size == 0:
+		lut.Is3D = true
+		lut.Size, lut.DomainMin, lut.DomainMax = table.size, table.domainMin, table.domainMax
+	default:
+		lut.Is3D = true
+		lut.Size, lut.DomainMin, lut.DomainMax = table.size, table.domainMin, table.domainMax
+		lut.Shaper = &CubeShaper{
+			Size:      shaper.size,
+			DomainMin: shaper.domainMin,
+			DomainMax: shaper.domainMax,
+			Data:      lut.Data[:3*shaper.size],
+		}
+		lut.Data = lut.Data[3*shaper.size:]
+	}
+	return lut, nil
+}
+
+func parseValues(fields []string, values []float32) error {
+	if len(fields) != len(values) {
+		return fmt.Errorf("expected %d values, got %d", len(values), len(fields))
+	}
+	for i, field := range fields {
+		value, err := strconv.ParseFloat(field, 32)
+		if err != nil {
+			return err
+		}
+		values[i] = float32(value)
+	}
+	return nil
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(s, 32)
+	return err == nil
+}
@@ -0,0 +1,113 @@
+package loaders
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCube1D(t *testing.T) {
+	input := "TITLE \"Invert\"\n# comment\nLUT_1D_SIZE 2\n1 1 1\n0 0 0\n"
+	lut, err := ParseCube(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lut.Title != "Invert" || lut.Is3D || lut.Size != 2 || lut.Shaper != nil {
+		t.Errorf("lut %+v, want 1D table of size 2", lut)
+	}
+	if lut.DomainMin != [3]float32{0, 0, 0} || lut.DomainMax != [3]float32{1, 1, 1} {
+		t.Errorf("default domain %v - %v", lut.DomainMin, lut.DomainMax)
+	}
+	want := []float32{1, 1, 1, 0, 0, 0}
+	if !equalFloats(lut.Data, want) {
+		t.Errorf("data %v, want %v", lut.Data, want)
+	}
+}
+
+func TestParseCube3D(t *testing.T) {
+	lut, err := ParseCube(strings.NewReader("LUT_3D_SIZE 2\n" + identity3D))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !lut.Is3D || lut.Size != 2 || lut.Shaper != nil {
+		t.Errorf("lut %+v, want 3D table of size 2", lut)
+	}
+	if len(lut.Data) != 3*8 || lut.Data[3] != 1 || lut.Data[4] != 0 {
+		t.Errorf("data %v, red index must change fastest", lut.Data)
+	}
+}
+
+// 1D table of Resolve file with both tables is kept as the shaper of the 3D one
+func TestParseCubeShaper(t *testing.T) {
+	input := "LUT_1D_SIZE 3\nLUT_1D_INPUT_RANGE 0 4\nLUT_3D_SIZE 2\nLUT_3D_INPUT_RANGE 0 2\n" +
+		"0 0 0\n1 1 1\n2 2 2\n" + identity3D
+	lut, err := ParseCube(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !lut.Is3D || lut.Size != 2 || len(lut.Data) != 3*8 {
+		t.Fatalf("lut %+v, want 3D table of size 2", lut)
+	}
+	if lut.DomainMax != [3]float32{2, 2, 2} {
+		t.Errorf("3D domain max %v, want 2", lut.DomainMax)
+	}
+	shaper := lut.Shaper
+	if shaper == nil {
+		t.Fatal("shaper is missing")
+	}
+	if shaper.Size != 3 || shaper.DomainMax != [3]float32{4, 4, 4} {
+		t.Errorf("shaper size %d, domain max %v", shaper.Size, shaper.DomainMax)
+	}
+	if !equalFloats(shaper.Data, []float32{0, 0, 0, 1, 1, 1, 2, 2, 2}) {
+		t.Errorf("shaper data %v", shaper.Data)
+	}
+}
+
+func TestParseCubeDomain(t *testing.T) {
+	input := "LUT_1D_SIZE 2\nDOMAIN_MIN -0.5 0 0\nDOMAIN_MAX 1.5 1 2\n0 0 0\n1 1 1\n"
+	lut, err := ParseCube(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lut.DomainMin != [3]float32{-0.5, 0, 0} || lut.DomainMax != [3]float32{1.5, 1, 2} {
+		t.Errorf("domain %v - %v", lut.DomainMin, lut.DomainMax)
+	}
+
+	input = "LUT_1D_SIZE 2\nDOMAIN_MIN 1 0 0\nDOMAIN_MAX 1 1 1\n0 0 0\n1 1 1\n"
+	if _, err := ParseCube(strings.NewReader(input)); err == nil {
+		t.Error("empty domain is accepted")
+	}
+}
+
+func TestParseCubeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"too few entries", "LUT_3D_SIZE 2\n0 0 0\n1 1 1\n"},
+		{"too many entries", "LUT_1D_SIZE 2\n0 0 0\n0.5 0.5 0.5\n1 1 1\n"},
+		{"no size", "0 0 0\n1 1 1\n"},
+		{"size twice", "LUT_1D_SIZE 2\nLUT_1D_SIZE 2\n0 0 0\n1 1 1\n"},
+		{"short entry", "LUT_1D_SIZE 2\n0 0\n1 1 1\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := ParseCube(strings.NewReader(test.input)); err == nil {
+				t.Error("broken table is parsed without error")
+			}
+		})
+	}
+}
+
+const identity3D = "0 0 0\n1 0 0\n0 1 0\n1 1 0\n0 0 1\n1 0 1\n0 1 1\n1 1 1\n"
+
+func equalFloats(a, b []float32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
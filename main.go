@@ -8,11 +8,28 @@ import (
 	"image"
 	"math"
 	"os"
+	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 	"videoplayer/buttons"
+	"videoplayer/container"
+	"videoplayer/cropdetect"
+	"videoplayer/loaders"
 	"videoplayer/multithread"
+	"videoplayer/pacing"
+	"videoplayer/picture"
+	"videoplayer/postfx"
+	"videoplayer/scaling"
+	"videoplayer/settings"
 	"videoplayer/shaders"
+	"videoplayer/subtitles"
+	"videoplayer/text"
+	"videoplayer/textures"
+	"videoplayer/transform"
 
 	"github.com/faiface/beep"
 	"github.com/faiface/beep/effects"
@@ -36,12 +53,23 @@ var renderVertices = []float32{
 }
 
 const (
-	frameBufferSize                   = 24
-	sampleRate                        = 44100
-	channelCount                      = 2
-	bitDepth                          = 8
-	sampleBufferSize                  = 32 * channelCount * bitDepth * 24
-	SpeakerSampleRate beep.SampleRate = 44100
+	frameBufferSize                     = 256 << 20 // in bytes
+	frameBufferMaxSize                  = 4 * frameBufferSize
+	bufferingFrames                     = 12 // frames to decode before playback resumes after buffering
+	defaultFrameRate                    = 25 // used when the stream doesn't report its frame rate
+	videoQueueSize                      = 4  // decoded frames waiting for the video goroutine
+	audioQueueSize                      = 64 // decoded frames waiting for the audio goroutine
+	sampleRate                          = 44100
+	channelCount                        = 2
+	bitDepth                            = 8
+	sampleBufferSize                    = 32 * channelCount * bitDepth * 24
+	sampleBufferMaxSize                 = 4 * sampleBufferSize
+	SpeakerSampleRate   beep.SampleRate = 44100
+	speakerLatency                      = time.Second / 10
+	zoomStep                            = 1.1 // zoom change per mouse wheel step
+	maxErrorLines                       = 8   // longer error messages are cut on the screen
+	subtitleDelayStep                   = 100 * time.Millisecond
+	windowTitle                         = "Video-Player"
 )
 
 var sampleSource *multithread.SharedBuffer
@@ -51,8 +79,46 @@ var stopped = false
 var soundCtrl *beep.Ctrl
 var soundVolume *effects.Volume
 
+// set by frame buffer watermark callbacks (1 - buffering, 0 - not)
+var buffering int32
+var printStats bool
+
+var mediaClock = &MediaClock{}
+var osd = &OSD{}
+
+var playerSettings = settings.Default()
+var rememberSettings bool
+
+// adjustments saved for the file, they are kept as they are without --remember
+var savedAdjustments picture.Adjustments
+
+var toneMapping = &picture.ToneMapping{}
+var deinterlacer = &picture.Deinterlacer{}
+var scaler *scaling.Scaler
+var videoView = transform.NewView()
+var cropDetector = cropdetect.New()
+var autoCrop bool
+var manualCrop image.Rectangle // set by --crop, used while auto crop is off
+
+// picture is being dragged by the mouse
+var dragging bool
+var dragX, dragY float64
+var lastClick time.Time
+
+var fullscreen = &Fullscreen{}
+
+var colorLUT *textures.LUT // nil if LUT isn't loaded
+var lutEnabled bool
+var pacer *pacing.Pacer
+
+// frames late for more than this are dropped (0 - never drop)
+var lateFrameThreshold time.Duration
+
 type Video struct {
-	ticker                 <-chan time.Time
+	droppedQueued          int64 // frames dropped before the frame buffer: late or not fitting the queue (atomic)
+	droppedRendered        int64 // late frames dropped by the render loop
+	corruptedFrames        int64 // frames which pixels don't match their size (atomic)
+	frameDuration          time.Duration
 	errs                   <-chan error
 	frameBuffer            *multithread.SharedBuffer
 	fps                    int
@@ -64,20 +130,102 @@ type Video struct {
 	last                   time.Time
 	width                  int32
 	height                 int32
+	lastStats              multithread.BufferStats // frame buffer stats at previous tuning
+	lastSampleStats        multithread.BufferStats // sample buffer stats at previous tuning
 }
 
 var videoStream *reisen.VideoStream // temporary solution
 
+// queues between demuxer and video/audio goroutines
+var videoQueue, audioQueue *multithread.SharedBuffer
+
 var video = &Video{}
 
 var buttonsBar *buttons.ButtonsBar
+var textRenderer *text.Renderer
+
+var subtitleTracks []*subtitles.Track // subtitle files first, then the embedded tracks
+var subtitleRenderer *subtitles.Renderer
+
+// primary and secondary subtitles
+var subtitleSlots = [2]*subtitleSlot{
+	{title: "Subtitles", visible: true, style: subtitles.DefaultStyle()},
+	{title: "Second subtitles", visible: true, style: subtitles.SecondaryStyle()},
+}
 
 var videoPath string
 
+// Flag which can be specified several times
+type stringList []string
+
+func (sl *stringList) String() string {
+	return strings.Join(*sl, ",")
+}
+
+func (sl *stringList) Set(value string) error {
+	*sl = append(*sl, value)
+	return nil
+}
+
 func main() {
+	var shaderPaths stringList
 	filePath := flag.String("file", "", "path to the video file")
+	flag.Var(&shaderPaths, "shader",
+		"fragment shader of the post-processing pass (can be specified several times)")
+	shaderList := flag.String("shader-list", "",
+		"file with post-processing fragment shader paths, one per line")
+	stats := flag.Bool("stats", false, "print buffer statistics every second")
+	dropLate := flag.Duration("drop-late", 100*time.Millisecond,
+		"drop video frames which are late for more than this (0 - never drop)")
+	pulldown := flag.Bool("pulldown", false,
+		"keep regular frame cadence (e.g. 3:2 for 24 fps on 60 Hz display)")
+	pixelBuffers := flag.Int("pbo", 3,
+		"number of pixel buffers used for asynchronous frame uploads (0 - synchronous uploads)")
+	benchUpload := flag.Bool("bench-upload", false, "print frame upload times every second")
+	transferName := flag.String("transfer", "auto",
+		"transfer function of the video: auto, sdr, pq (HDR10), hlg")
+	primariesName := flag.String("primaries", "auto", "colour primaries of the video: auto, bt709, bt2020")
+	toneMapName := flag.String("tonemap", "bt2390",
+		"HDR -> SDR tone mapping operator: clip, reinhard, hable, bt2390")
+	hdrPeak := flag.Float64("hdr-peak", 1000, "peak luminance of HDR video in nits")
+	deinterlaceName := flag.String("deinterlace", "auto",
+		"deinterlacing mode: auto (by the stream flags), off, bob, adaptive")
+	fieldOrderName := flag.String("field-order", "auto",
+		"field order of interlaced video: auto, tff (top field first), bff")
+	scaleName := flag.String("scale", "bilinear",
+		"scaling filter of the video: nearest, bilinear, bicubic, lanczos")
+	aspectName := flag.String("aspect", "auto",
+		"display aspect ratio of the video: auto, W:H (e.g. 4:3, 16:9) or ratio (e.g. 2.35)")
+	cropName := flag.String("crop", "", "shown part of the frame in pixels: W:H:X:Y")
+	cropDetect := flag.Bool("cropdetect", false, "detect black bars and crop them")
+	rotateName := flag.String("rotate", "auto",
+		"clockwise rotation of the video: auto (by the file metadata), 0, 90, 180, 270")
+	flipH := flag.Bool("hflip", false, "mirror the video horizontally")
+	flipV := flag.Bool("vflip", false, "mirror the video vertically")
+	startFullscreen := flag.Bool("fullscreen", false, "start in fullscreen mode")
+	monitorIndex := flag.Int("monitor", 0, "monitor used for fullscreen mode (0 - primary)")
+	subtitlePath := flag.String("sub", "",
+		"subtitle file (.srt, .ass, .ssa, .vtt), by default the file with the video name is loaded if it exists")
+	flag.DurationVar(&subtitleSlots[0].delay, "sub-delay", 0, "delay of the subtitles (negative shows them earlier)")
+	subtitleStyle := flag.String("sub-style", "",
+		"style of the subtitles: size (percent of the picture height), color, position (top, middle, bottom), "+
+			"e.g. size=5,color=#ffffff,position=bottom")
+	secondSubtitlePath := flag.String("sub2", "",
+		"second subtitle file shown at the same time (dual subtitles), at the top by default")
+	flag.DurationVar(&subtitleSlots[1].delay, "sub2-delay", 0, "delay of the second subtitles")
+	secondSubtitleStyle := flag.String("sub2-style", "", "style of the second subtitles, like --sub-style")
+	timeFormatName := flag.String("time-format", "clock",
+		"format of the playback time: clock, frames, timecode (SMPTE)")
+	fontPath := flag.String("font", "", "TrueType/OpenType font of the on-screen text (built-in Go font by default)")
+	lutPath := flag.String("lut", "", "colour lookup table (.cube) applied to the video")
+	remember := flag.Bool("remember", false,
+		"restore picture adjustments saved for the file and save their changes "+
+			"(chosen subtitle tracks and their delays are always remembered)")
 	flag.Parse()
 	videoPath = *filePath
+	printStats = *stats
+	lateFrameThreshold = *dropLate
+	rememberSettings = *remember
 	if videoPath == "" {
 		fmt.Println("File path is not specified")
 		fmt.Println("Print `--help` to get more info")
@@ -88,6 +236,116 @@ func main() {
 		fmt.Printf("%v file does not exist\nPrint `--help` to get more info\n", videoPath)
 		return
 	}
+	toneMapping.Transfer, err = picture.ParseTransfer(*transferName)
+	if err != nil {
+		fmt.Printf("%v\nPrint `--help` to get more info\n", err)
+		return
+	}
+	toneMapping.Primaries, err = picture.ParsePrimaries(*primariesName)
+	if err != nil {
+		fmt.Printf("%v\nPrint `--help` to get more info\n", err)
+		return
+	}
+	toneMapping.Operator, err = picture.ParseToneMap(*toneMapName)
+	if err != nil {
+		fmt.Printf("%v\nPrint `--help` to get more info\n", err)
+		return
+	}
+	toneMapping.Peak = float32(*hdrPeak)
+	// decoder doesn't expose colour metadata, it's read from the container
+	videoInfo, err := container.Probe(videoPath)
+	if err != nil {
+		fmt.Printf("Can't read video metadata: %v\n", err)
+		videoInfo = &container.VideoInfo{}
+	}
+	toneMapping.Resolve(videoInfo)
+	deinterlacer.Mode, err = picture.ParseDeinterlaceMode(*deinterlaceName, videoInfo)
+	if err != nil {
+		fmt.Printf("%v\nPrint `--help` to get more info\n", err)
+		return
+	}
+	deinterlacer.TopFirst, err = picture.ParseFieldOrder(*fieldOrderName, videoInfo)
+	if err != nil {
+		fmt.Printf("%v\nPrint `--help` to get more info\n", err)
+		return
+	}
+	scaleFilter, err := scaling.ParseFilter(*scaleName)
+	if err != nil {
+		fmt.Printf("%v\nPrint `--help` to get more info\n", err)
+		return
+	}
+	videoView.Aspect, err = transform.ParseAspect(*aspectName)
+	if err != nil {
+		fmt.Printf("%v\nPrint `--help` to get more info\n", err)
+		return
+	}
+	if *cropName != "" {
+		manualCrop, err = transform.ParseCrop(*cropName)
+		if err != nil {
+			fmt.Printf("%v\nPrint `--help` to get more info\n", err)
+			return
+		}
+		videoView.Crop = manualCrop
+	}
+	autoCrop = *cropDetect
+	fullscreen.monitorIndex = *monitorIndex
+	if *rotateName == "auto" {
+		videoView.Rotation = videoInfo.Rotation
+		videoView.FlipH = videoInfo.Mirrored
+	} else {
+		rotation, err := strconv.Atoi(*rotateName)
+		if err != nil || rotation%90 != 0 {
+			fmt.Printf("invalid rotation: %s\nPrint `--help` to get more info\n", *rotateName)
+			return
+		}
+		videoView.Rotate(rotation / 90)
+	}
+	videoView.FlipH = videoView.FlipH != *flipH
+	videoView.FlipV = *flipV
+	if *shaderList != "" {
+		paths, err := readShaderList(*shaderList)
+		if err != nil {
+			fmt.Printf("Can't read shader list: %v\n", err)
+			return
+		}
+		shaderPaths = append(shaderPaths, paths...)
+	}
+	timeFormat, err := buttons.ParseTimeFormat(*timeFormatName)
+	if err != nil {
+		fmt.Printf("%v\nPrint `--help` to get more info\n", err)
+		return
+	}
+	font := text.DefaultFont()
+	if *fontPath != "" {
+		font, err = text.LoadFont(*fontPath)
+		if err != nil {
+			fmt.Printf("Can't load font: %v\n", err)
+			return
+		}
+	}
+	for i, style := range []string{*subtitleStyle, *secondSubtitleStyle} {
+		subtitleSlots[i].style, err = subtitles.ParseStyle(style, subtitleSlots[i].style)
+		if err != nil {
+			fmt.Printf("%v\nPrint `--help` to get more info\n", err)
+			return
+		}
+	}
+	// subtitle choice is restored always, picture adjustments only with --remember
+	playerSettings, err = settings.Load(videoPath)
+	if err != nil {
+		fmt.Printf("Can't load saved settings: %v\n", err)
+	}
+	savedAdjustments = playerSettings.Adjustments
+	if !rememberSettings {
+		playerSettings.Adjustments = picture.Default()
+	}
+	loadSubtitles([2]string{*subtitlePath, *secondSubtitlePath})
+	embeddedSubtitles := make(chan embeddedTracks, 1)
+	go func() {
+		// the whole file is read, so it's done while the video is playing
+		tracks, err := subtitles.LoadEmbedded(videoPath)
+		embeddedSubtitles <- embeddedTracks{tracks, err}
+	}()
 
 	runtime.LockOSThread()
 
@@ -103,18 +361,21 @@ func main() {
 	glfw.WindowHint(glfw.OpenGLProfile, glfw.OpenGLCoreProfile)
 	glfw.WindowHint(glfw.OpenGLForwardCompatible, glfw.True)
 
-	window, err := glfw.CreateWindow(600, 600, "Video-Player", nil, nil)
+	window, err := glfw.CreateWindow(600, 600, windowTitle, nil, nil)
 	if err != nil {
 		panic(err)
 	}
 
 	window.MakeContextCurrent()
+	// frames are paced by vblanks, see pacing package
+	glfw.SwapInterval(1)
 	window.SetFramebufferSizeCallback(changeViewportSize)
+	window.SetContentScaleCallback(changeContentScale)
 	window.SetKeyCallback(keyCallback)
 	window.SetInputMode(glfw.CursorMode, glfw.CursorNormal)
 	window.SetMouseButtonCallback(mouseCallback)
-	// window.SetCursorPosCallback(mouseCallback)
-	// window.SetScrollCallback(scrollCallback)
+	window.SetCursorPosCallback(cursorPosCallback)
+	window.SetScrollCallback(scrollCallback)
 
 	err = gl.Init()
 	if err != nil {
@@ -123,6 +384,24 @@ func main() {
 
 	videoShader := shaders.New("shaders/video.vs", "shaders/video.fs")
 	buttonsShader := shaders.New("shaders/buttons.vs", "shaders/buttons.fs")
+	textShader := shaders.New("shaders/text.vs", "shaders/text.fs")
+	textRenderer = text.NewRenderer(window, textShader, font)
+	subtitleRenderer = subtitles.NewRenderer(textRenderer)
+	scaler = scaling.New("shaders/scale.vs", "shaders/scale.fs", scaleFilter)
+	if *lutPath != "" {
+		cube, err := loaders.LoadCube(*lutPath)
+		if err != nil {
+			showError(err)
+		} else {
+			colorLUT = textures.NewLUT(cube)
+			lutEnabled = true
+		}
+	}
+	postProcessing, err := postfx.NewChain("shaders/postfx.vs", shaderPaths)
+	handleError(err)
+	for _, err := range postProcessing.Errors() {
+		showError(err)
+	}
 
 	var videoVAO, videoVBO, buttonsVAO, buttonsVBO uint32
 
@@ -150,34 +429,61 @@ func main() {
 
 	err = video.Start(videoPath)
 	handleError(err)
+	if num, den := videoStream.AspectRatio(); num > 0 && den > 0 {
+		videoView.SampleAspect = float64(num) / float64(den)
+	}
+
+	if *startFullscreen {
+		err = fullscreen.Enter(window)
+		if err != nil {
+			showError(err)
+		}
+	}
+	refreshRate := fullscreen.WindowMonitor(window).GetVideoMode().RefreshRate
+	if refreshRate <= 0 {
+		refreshRate = 60
+	}
+	pacer = pacing.New(time.Second/time.Duration(refreshRate), video.frameDuration, *pulldown)
 
 	// wWidth, wHeight := window.GetSize()
 	// setViewport(int32(wWidth), int32(wHeight), video.width, video.height)
-	texture := initTexture(video.width, video.height)
+	texture := textures.NewVideoTexture(video.width, video.height)
+	texture.SetPixelBuffers(*pixelBuffers)
+	if *benchUpload {
+		texture.EnableBenchmark()
+	}
 
-	var firstFrame, lastFrame *image.RGBA
+	var firstFrame, lastFrame *multithread.Frame
+	var displayTime time.Duration
+	var videoMatrix mgl32.Mat4
 
 	buttonsBar = buttons.NewButtonsBar(
 		window,
 		buttonsShader,
 		buttonsVAO,
+		textRenderer,
 		true,
 	)
+	timeDisplay := buttonsBar.GetTimeDisplay()
+	timeDisplay.Format = timeFormat
+	timeDisplay.FrameDuration = video.frameDuration
 
 	for !window.ShouldClose() {
 		gl.ClearColor(0.0, 0.0, 0.0, 1.0)
 		gl.Clear(gl.COLOR_BUFFER_BIT)
 
+		fbWidth, fbHeight := window.GetFramebufferSize()
+
 		// Render video
 		shaders.Use(videoShader)
-		gl.ActiveTexture(gl.TEXTURE0)
-		gl.BindTexture(gl.TEXTURE_2D, texture)
-		<-video.ticker // need to refactor
 		if isPlaying {
-			frame, _ := video.frameBuffer.Read()
+			displayTime = pacer.Advance(mediaClock.Time())
+			frame := nextFrame(displayTime)
 			if frame != nil {
-				video.videoTotalFramesPlayed++ // increase number of already played frames
-				lastFrame = frame.(*image.RGBA)
+				// position by frame timestamp accounts dropped frames as well
+				video.videoTotalFramesPlayed = int64(frame.Pts.Seconds() /
+					video.videoDuration * float64(video.videoTotalFrames))
+				lastFrame = frame
 			}
 			// TODO:
 			// After stream reaches the end it will be closed
@@ -190,62 +496,98 @@ func main() {
 			lastFrame = firstFrame
 			video.videoTotalFramesPlayed = 0
 		}
-		gl.TexSubImage2D(gl.TEXTURE_2D, 0, 0, 0, video.width, video.height, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(lastFrame.Pix))
-		gl.BindVertexArray(videoVAO)
-		videoMatrix := getVideoMatrix(window, video.width, video.height)
-		shaders.SetMat4(videoShader, "view", &videoMatrix)
-		gl.DrawArrays(gl.TRIANGLES, 0, 6)
+		// there is nothing to show until the first frame is decoded
+		if lastFrame != nil {
+			// motion adaptive deinterlacing compares frame with the previous one
+			texture.SetKeepPrevious(deinterlacer.Mode == picture.DeinterlaceAdaptive)
+			texture.Upload(lastFrame)
+			if autoCrop {
+				cropDetector.Analyze(lastFrame)
+				videoView.Crop = cropDetector.Crop()
+			}
+			texture.Bind(videoShader)
+			deinterlacer.Apply(videoShader, deinterlacer.Field(lastFrame.Pts, displayTime, video.frameDuration))
+			toneMapping.Apply(videoShader)
+			playerSettings.Adjustments.Apply(videoShader)
+			if colorLUT != nil && lutEnabled {
+				colorLUT.Bind(videoShader)
+			} else {
+				textures.DisableLUT(videoShader)
+			}
+			// picture is rendered at frame resolution first and then scaled to the window,
+			// frame size may change in the middle of the stream
+			frameWidth, frameHeight := texture.Size()
+			scaler.Begin(frameWidth, frameHeight)
+			identity := mgl32.Ident4()
+			shaders.SetMat4(videoShader, "view", &identity)
+			gl.BindVertexArray(videoVAO)
+			gl.DrawArrays(gl.TRIANGLES, 0, 6)
+
+			videoMatrix = getVideoMatrix(window, frameWidth, frameHeight)
+			outWidth, outHeight := scaling.OutputSize(videoMatrix, fbWidth, fbHeight)
+			scaler.Prepare(videoVAO, outWidth, outHeight)
+		}
 		gl.ActiveTexture(0)
 		gl.BindVertexArray(0)
+		postProcessing.Begin(int32(fbWidth), int32(fbHeight))
+		if lastFrame != nil {
+			frameWidth, frameHeight := texture.Size()
+			clip := videoView.ClipRect(fbWidth, fbHeight, frameWidth, frameHeight)
+			scaler.Draw(videoVAO, &videoMatrix, clip)
+		}
+		postProcessing.Apply(videoVAO)
+		if lastFrame != nil {
+			drawSubtitles(window, texture)
+		}
+
+		select {
+		case <-video.perSecond:
+			tuneBuffers()
+			for _, err := range postProcessing.Reload() {
+				showError(err)
+			}
+			if printStats {
+				fmt.Println(formatStats())
+			}
+			if *benchUpload {
+				fmt.Println(formatUploadStats(texture.UploadStats()))
+			}
+		case embedded := <-embeddedSubtitles:
+			addEmbeddedSubtitles(embedded)
+		default:
+		}
+
+		isBuffering := atomic.LoadInt32(&buffering) == 1 && isPlaying
 
 		// Render buttons
 		videoProgress := getVideoProgress()
 		buttonsBar.MoveScrollerHandle(videoProgress)
+		timeDisplay.Set(getPlaybackTime(), time.Duration(video.videoDuration*float64(time.Second)))
 		buttonsBar.Draw()
 		// buttons.DrawButtonsBar(window, buttonsShader, buttonsVAO)
 
+		osd.Draw(textRenderer, isBuffering)
+
 		window.SwapBuffers()
-		glfw.SwapInterval(1)
+		pacer.Swapped(time.Duration(glfw.GetTime() * float64(time.Second)))
 		glfw.PollEvents()
 	}
 }
 
-func initTexture(width, height int32) uint32 {
-	var textureID uint32
-	gl.GenTextures(1, &textureID)
-
-	gl.BindTexture(gl.TEXTURE_2D, textureID)
-	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA, width, height, 0, gl.RGBA, gl.UNSIGNED_BYTE, nil)
-	gl.GenerateMipmap(gl.TEXTURE_2D)
-
-	// gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.REPEAT)
-	// gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.REPEAT)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
-
-	return textureID
-}
-
 func changeViewportSize(window *glfw.Window, width, height int) {
 	gl.Viewport(0, 0, int32(width), int32(height))
 	buttonsBar.UpdatePos()
 	// setViewport(int32(width), int32(height), video.width, video.height)
 }
 
+// Window moved to the monitor with other scale
+func changeContentScale(window *glfw.Window, x, y float32) {
+	buttonsBar.UpdatePos()
+}
+
 func getVideoMatrix(window *glfw.Window, vWidth, vHeight int32) mgl32.Mat4 {
-	wWidth, wHeight := window.GetSize()
-	windowAspectRatio := float64(wWidth) / float64(wHeight)
-	videoAspectRatio := float64(vWidth) / float64(vHeight)
-	var scaleX, scaleY float32
-	if windowAspectRatio >= videoAspectRatio {
-		scaleY = 1
-		scaleX = float32(videoAspectRatio) / float32(windowAspectRatio)
-	} else {
-		scaleX = 1
-		scaleY = float32(windowAspectRatio) / float32(videoAspectRatio)
-	}
-	viewMatrix := mgl32.Scale3D(scaleX, scaleY, 1)
-	return viewMatrix
+	fbWidth, fbHeight := window.GetFramebufferSize()
+	return videoView.Matrix(fbWidth, fbHeight, vWidth, vHeight)
 }
 
 // func setViewport(wWidth, wHeight, vWidth, vHeight int32) {
@@ -272,7 +614,7 @@ func readVideoAndAudio(media *reisen.Media) (
 	chan error,
 	error,
 ) {
-	frameBuffer := multithread.NewSharedBuffer(frameBufferSize)
+	frameBuffer := multithread.NewSharedBufferBytes(frameBufferSize, multithread.FrameSize)
 	sampleBuffer := multithread.NewSharedBuffer(sampleBufferSize)
 	errs := make(chan error)
 
@@ -282,6 +624,10 @@ func readVideoAndAudio(media *reisen.Media) (
 		return nil, nil, 0, 0, nil, err
 	}
 
+	// subtitle and other streams are left closed, their packets are skipped
+	if len(media.VideoStreams()) == 0 || len(media.AudioStreams()) == 0 {
+		return nil, nil, 0, 0, nil, fmt.Errorf("the file has to have video and audio streams")
+	}
 	videoStream = media.VideoStreams()[0]
 	err = videoStream.Open()
 
@@ -309,9 +655,91 @@ func readVideoAndAudio(media *reisen.Media) (
 		return nil, nil, nil, err
 	}*/
 
+	videoQueue = multithread.NewSharedBuffer(videoQueueSize)
+	audioQueue = multithread.NewSharedBuffer(audioQueueSize)
+
+	var decoders sync.WaitGroup
+	decoders.Add(2)
+	go func() {
+		decodeVideo(videoQueue, frameBuffer)
+		decoders.Done()
+	}()
+	go func() {
+		decodeAudio(audioQueue, sampleBuffer, errs)
+		decoders.Done()
+	}()
+
 	go func() {
-		for {
-			packet, gotPacket, err := media.ReadPacket()
+		demux(media, videoQueue, audioQueue, errs)
+
+		decoders.Wait()
+		videoStream.Close()
+		audioStream.Close()
+		media.CloseDecode()
+		close(errs)
+	}()
+
+	return frameBuffer, sampleBuffer, videoWidth, videoHeight, errs, nil
+}
+
+// Reads packets of both streams, decodes them and sends decoded frames
+// to the queues of the video and audio goroutines.
+//
+// Decoding can't be moved to per-stream goroutines: reisen streams decode
+// the packet stored in the media by the last ReadPacket call,
+// so a packet has to be decoded before the next one is read
+func demux(
+	media *reisen.Media,
+	videoQueue, audioQueue *multithread.SharedBuffer,
+	errs chan error,
+) {
+	for {
+		packet, gotPacket, err := media.ReadPacket()
+
+		if err != nil {
+			go func(err error) {
+				errs <- err
+			}(err)
+		}
+
+		if !gotPacket {
+			break
+		}
+
+		/*hash := sha256.Sum256(packet.Data())
+		fmt.Println(base58.Encode(hash[:]))*/
+
+		switch packet.Type() {
+		case reisen.StreamVideo:
+			// streams without decoder are unknown ones
+			s, ok := media.Streams()[packet.StreamIndex()].(*reisen.VideoStream)
+			if !ok || !s.Opened() {
+				continue
+			}
+			videoFrame, gotFrame, err := s.ReadVideoFrame()
+
+			if err != nil {
+				go func(err error) {
+					errs <- err
+				}(err)
+			}
+
+			if !gotFrame {
+				break
+			}
+
+			if videoFrame == nil {
+				continue
+			}
+
+			enqueueVideo(videoQueue, audioQueue, videoFrame)
+
+		case reisen.StreamAudio:
+			s, ok := media.Streams()[packet.StreamIndex()].(*reisen.AudioStream)
+			if !ok || !s.Opened() {
+				continue
+			}
+			audioFrame, gotFrame, err := s.ReadAudioFrame()
 
 			if err != nil {
 				go func(err error) {
@@ -319,101 +747,119 @@ func readVideoAndAudio(media *reisen.Media) (
 				}(err)
 			}
 
-			if !gotPacket {
+			if !gotFrame {
 				break
 			}
 
-			/*hash := sha256.Sum256(packet.Data())
-			fmt.Println(base58.Encode(hash[:]))*/
-
-			switch packet.Type() {
-			case reisen.StreamVideo:
-				s := media.Streams()[packet.StreamIndex()].(*reisen.VideoStream)
-				videoFrame, gotFrame, err := s.ReadVideoFrame()
-
-				if err != nil {
-					go func(err error) {
-						errs <- err
-					}(err)
-				}
-
-				if !gotFrame {
-					break
-				}
-
-				if videoFrame == nil {
-					continue
-				}
-
-				// frameBuffer <- videoFrame.Image()
-				frameBuffer.Write(videoFrame.Image())
-
-			case reisen.StreamAudio:
-				s := media.Streams()[packet.StreamIndex()].(*reisen.AudioStream)
-				audioFrame, gotFrame, err := s.ReadAudioFrame()
-
-				if err != nil {
-					go func(err error) {
-						errs <- err
-					}(err)
-				}
-
-				if !gotFrame {
-					break
-				}
-
-				if audioFrame == nil {
-					continue
-				}
-
-				// Turn the raw byte data into
-				// audio samples of type [2]float64.
-				reader := bytes.NewReader(audioFrame.Data())
-
-				// See the README.md file for
-				// detailed scheme of the sample structure.
-				for reader.Len() > 0 {
-					sample := [2]float64{0, 0}
-					var result float64
-					err = binary.Read(reader, binary.LittleEndian, &result)
-
-					if err != nil {
-						go func(err error) {
-							errs <- err
-						}(err)
-					}
-
-					sample[0] = result
-
-					err = binary.Read(reader, binary.LittleEndian, &result)
-
-					if err != nil {
-						go func(err error) {
-							errs <- err
-						}(err)
-					}
-
-					sample[1] = result
-					sampleBuffer.Write(sample)
-				}
+			if audioFrame == nil {
+				continue
 			}
+
+			// the speaker keeps draining the sample buffer,
+			// so waiting for free space doesn't block for long
+			audioQueue.Write(audioFrame)
 		}
+	}
 
-		videoStream.Close()
-		audioStream.Close()
-		media.CloseDecode()
-		frameBuffer.Close()
-		sampleBuffer.Close()
-		close(errs)
-	}()
+	videoQueue.Close()
+	audioQueue.Close()
+}
 
-	return frameBuffer, sampleBuffer, videoWidth, videoHeight, errs, nil
+// Waits for free space in the video queue while the audio goroutine
+// has something to work on. Once audio runs out of data the frame is dropped
+// (and counted in droppedQueued): the video queue is drained by the render loop,
+// which follows the audio clock, and the clock stops when the audio starves.
+// Waiting there would block the demuxer, the only feeder of the audio queue,
+// so playback would deadlock
+func enqueueVideo(videoQueue, audioQueue *multithread.SharedBuffer, frame *reisen.VideoFrame) {
+	audioQueued := func() bool {
+		return audioQueue.Size() > 0
+	}
+	if !videoQueue.WriteWhile(frame, audioQueued) {
+		atomic.AddInt64(&video.droppedQueued, 1)
+	}
+}
+
+func decodeVideo(videoQueue, frameBuffer *multithread.SharedBuffer) {
+	for {
+		item, _ := videoQueue.Read()
+		if item == nil {
+			break
+		}
+		videoFrame := item.(*reisen.VideoFrame)
+		pts, err := videoFrame.PresentationOffset()
+		if err != nil {
+			continue
+		}
+
+		// frame is dropped before it gets buffered
+		if isLate(pts) {
+			atomic.AddInt64(&video.droppedQueued, 1)
+			continue
+		}
+
+		// reisen allocates new image for every frame (there is no way to give it
+		// a destination buffer), so the frame takes the image without copying
+		frame := multithread.NewFrame(videoFrame.Image(), pts)
+		if frame == nil {
+			// e.g. decoder didn't reallocate its buffer after resolution change
+			atomic.AddInt64(&video.corruptedFrames, 1)
+			continue
+		}
+		frameBuffer.Write(frame)
+	}
+	frameBuffer.Close()
+}
+
+func decodeAudio(audioQueue, sampleBuffer *multithread.SharedBuffer, errs chan error) {
+	for {
+		item, _ := audioQueue.Read()
+		if item == nil {
+			break
+		}
+		audioFrame := item.(*reisen.AudioFrame)
+		if pts, err := audioFrame.PresentationOffset(); err == nil {
+			sampleBuffer.Write(audioTimestamp(pts))
+		}
+
+		// Turn the raw byte data into
+		// audio samples of type [2]float64.
+		reader := bytes.NewReader(audioFrame.Data())
+
+		// See the README.md file for
+		// detailed scheme of the sample structure.
+		for reader.Len() > 0 {
+			sample := [2]float64{0, 0}
+			var result float64
+			err := binary.Read(reader, binary.LittleEndian, &result)
+
+			if err != nil {
+				go func(err error) {
+					errs <- err
+				}(err)
+			}
+
+			sample[0] = result
+
+			err = binary.Read(reader, binary.LittleEndian, &result)
+
+			if err != nil {
+				go func(err error) {
+					errs <- err
+				}(err)
+			}
+
+			sample[1] = result
+			sampleBuffer.Write(sample)
+		}
+	}
+	sampleBuffer.Close()
 }
 
 func (video *Video) Start(fname string) error {
 	// Initialize the audio speaker.
 	err := speaker.Init(sampleRate,
-		SpeakerSampleRate.N(time.Second/10))
+		SpeakerSampleRate.N(speakerLatency))
 
 	if err != nil {
 		return err
@@ -436,26 +882,31 @@ func (video *Video) Start(fname string) error {
 
 	// Get the FPS for playing
 	// video frames.
-	videoFPS, _ := media.Streams()[0].FrameRate()
-
-	// Get the total frames count
-	videoTotalFramesCount := media.Streams()[0].FrameCount()
-
-	if err != nil {
-		return err
+	if len(media.VideoStreams()) == 0 {
+		return fmt.Errorf("the file has no video stream")
 	}
+	// frame rate is a fraction like 30000/1001
+	rateNum, rateDen := media.VideoStreams()[0].FrameRate()
 
-	// SPF for frame ticker.
-	spf := 1.0 / float64(videoFPS)
-	frameDuration, err := time.
-		ParseDuration(fmt.Sprintf("%fs", spf))
+	// Get the total frames count
+	videoTotalFramesCount := media.VideoStreams()[0].FrameCount()
 
-	if err != nil {
-		return err
+	// SPF for frame pacing.
+	spf := 1.0 / defaultFrameRate
+	if rateNum > 0 && rateDen > 0 {
+		spf = float64(rateDen) / float64(rateNum)
 	}
+	frameDuration := time.Duration(spf * float64(time.Second))
 
 	// Get video duration in seconds
-	videoDuration := spf * float64(videoTotalFramesCount)
+	videoDuration := mediaDuration(media).Seconds()
+	if videoDuration <= 0 {
+		videoDuration = spf * float64(videoTotalFramesCount)
+	}
+	// many containers (e.g. MKV) don't store the number of frames
+	if videoTotalFramesCount <= 0 {
+		videoTotalFramesCount = int64(math.Round(videoDuration / spf))
+	}
 
 	// Start decoding streams.
 	video.frameBuffer, sampleSource,
@@ -465,6 +916,19 @@ func (video *Video) Start(fname string) error {
 		return err
 	}
 
+	// Frame buffer running dry means decoding can't keep up with playback,
+	// playback resumes once a few frames are buffered again
+	bufferedSize := bufferingFrames * 4 * int(video.width) * int(video.height)
+	if bufferedSize > frameBufferSize/2 {
+		bufferedSize = frameBufferSize / 2
+	}
+	video.frameBuffer.SetWatermarks(
+		0,
+		bufferedSize,
+		func() { atomic.StoreInt32(&buffering, 1) },
+		func() { atomic.StoreInt32(&buffering, 0) },
+	)
+
 	// Start playing audio samples.
 	streamer := streamSamples(sampleSource)
 	soundCtrl = &beep.Ctrl{Streamer: streamer, Paused: false}
@@ -476,7 +940,7 @@ func (video *Video) Start(fname string) error {
 	}
 	speaker.Play(soundVolume)
 
-	video.ticker = time.Tick(frameDuration)
+	video.frameDuration = frameDuration
 
 	// Setup metrics.
 	video.last = time.Now()
@@ -490,6 +954,119 @@ func (video *Video) Start(fname string) error {
 	return nil
 }
 
+// Grows buffers which ran dry during the last second
+// (up to their max size)
+func tuneBuffers() {
+	frameStats := video.frameBuffer.Stats()
+	if frameStats.Underruns > video.lastStats.Underruns && frameStats.Capacity < frameBufferMaxSize {
+		newCapacity := frameStats.Capacity + frameStats.Capacity/2
+		if newCapacity > frameBufferMaxSize {
+			newCapacity = frameBufferMaxSize
+		}
+		video.frameBuffer.SetCapacity(newCapacity)
+	}
+	video.lastStats = frameStats
+
+	sampleStats := sampleSource.Stats()
+	if sampleStats.Underruns > video.lastSampleStats.Underruns && sampleStats.Capacity < sampleBufferMaxSize {
+		newCapacity := sampleStats.Capacity + sampleStats.Capacity/2
+		if newCapacity > sampleBufferMaxSize {
+			newCapacity = sampleBufferMaxSize
+		}
+		sampleSource.SetCapacity(newCapacity)
+	}
+	video.lastSampleStats = sampleStats
+}
+
+func formatStats() string {
+	frameStats := video.frameBuffer.Stats()
+	sampleStats := sampleSource.Stats()
+	return fmt.Sprintf(
+		"display: %.2f Hz | "+
+			"dropped: queue %d render %d corrupted %d | "+
+			"queues: video %d audio %d | "+
+			"frames: %d (%d/%d MB, peak %d MB) underruns %d overruns %d purges %d | "+
+			"samples: %d/%d (peak %d) underruns %d overruns %d purges %d",
+		float64(time.Second)/float64(pacer.Refresh()),
+		atomic.LoadInt64(&video.droppedQueued), video.droppedRendered,
+		atomic.LoadInt64(&video.corruptedFrames),
+		videoQueue.Size(), audioQueue.Size(),
+		frameStats.Size, frameStats.Used>>20, frameStats.Capacity>>20, frameStats.Peak>>20,
+		frameStats.Underruns, frameStats.Overruns, frameStats.Purges,
+		sampleStats.Size, sampleStats.Capacity, sampleStats.Peak,
+		sampleStats.Underruns, sampleStats.Overruns, sampleStats.Purges,
+	)
+}
+
+// Returns the latest buffered frame which is due at displayTime
+// or nil if the current frame should stay on the screen.
+// Older due frames are dropped
+func nextFrame(displayTime time.Duration) *multithread.Frame {
+	var frame *multithread.Frame
+	for {
+		item, ok := video.frameBuffer.Peek()
+		if !ok {
+			break
+		}
+		next := item.(*multithread.Frame)
+		if !pacer.Due(next.Pts, displayTime) {
+			break
+		}
+		video.frameBuffer.Read()
+		if frame != nil {
+			video.droppedRendered++
+		}
+		frame = next
+	}
+	return frame
+}
+
+func isLate(pts time.Duration) bool {
+	return lateFrameThreshold > 0 && mediaClock.Time()-pts > lateFrameThreshold
+}
+
+func formatUploadStats(stats textures.UploadStats) string {
+	if stats.Frames == 0 {
+		return fmt.Sprintf("upload: 0 frames, %d skipped", stats.Skipped)
+	}
+	return fmt.Sprintf(
+		"upload: %d frames, %d skipped | cpu avg %.2f ms max %.2f ms | gpu avg %.2f ms max %.2f ms",
+		stats.Frames, stats.Skipped,
+		float64(stats.CPUTime)/float64(stats.Frames)/float64(time.Millisecond),
+		float64(stats.CPUMax)/float64(time.Millisecond),
+		float64(stats.GPUTime)/float64(stats.Frames)/float64(time.Millisecond),
+		float64(stats.GPUMax)/float64(time.Millisecond),
+	)
+}
+
+// Paths of the shaders listed in the file, empty lines and lines starting with # are skipped
+func readShaderList(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		paths = append(paths, line)
+	}
+	return paths, nil
+}
+
+// Reports non-fatal error, player keeps working
+func showError(err error) {
+	fmt.Println(err)
+	// e.g. shader compile logs are too long for the screen
+	lines := strings.Split(strings.TrimSpace(err.Error()), "\n")
+	if len(lines) > maxErrorLines {
+		lines = append(lines[:maxErrorLines], "...")
+	}
+	osd.ShowError(strings.Join(lines, "\n"))
+}
+
 func handleError(err error) {
 	if err != nil {
 		panic(err)
@@ -499,6 +1076,7 @@ func handleError(err error) {
 func streamSamples(sampleSource *multithread.SharedBuffer) beep.Streamer {
 	return beep.StreamerFunc(func(samples [][2]float64) (n int, ok bool) {
 		numRead := 0
+		played := 0 // samples played since the last audio frame timestamp
 
 		for i := 0; i < len(samples); i++ {
 			sample, ok := sampleSource.Read()
@@ -508,9 +1086,19 @@ func streamSamples(sampleSource *multithread.SharedBuffer) beep.Streamer {
 				break
 			}
 
+			if pts, isTimestamp := sample.(audioTimestamp); isTimestamp {
+				mediaClock.AddSamples(played)
+				mediaClock.SetAudioPts(time.Duration(pts))
+				played = 0
+				i--
+				continue
+			}
+
 			samples[i] = sample.([2]float64)
 			numRead++
+			played++
 		}
+		mediaClock.AddSamples(played)
 
 		if numRead < len(samples) {
 			return numRead, false
@@ -520,10 +1108,359 @@ func streamSamples(sampleSource *multithread.SharedBuffer) beep.Streamer {
 	})
 }
 
+// keys decreasing and increasing picture parameters
+var adjustmentKeys = map[glfw.Key]struct {
+	param picture.Param
+	steps int
+}{
+	glfw.Key1: {picture.Contrast, -1},
+	glfw.Key2: {picture.Contrast, 1},
+	glfw.Key3: {picture.Brightness, -1},
+	glfw.Key4: {picture.Brightness, 1},
+	glfw.Key5: {picture.Gamma, -1},
+	glfw.Key6: {picture.Gamma, 1},
+	glfw.Key7: {picture.Saturation, -1},
+	glfw.Key8: {picture.Saturation, 1},
+	glfw.Key9: {picture.Hue, -1},
+	glfw.Key0: {picture.Hue, 1},
+}
+
 func keyCallback(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
 	if key == glfw.KeySpace && action == glfw.Release {
 		playPause()
 	}
+	if action == glfw.Release {
+		return
+	}
+	if adjustment, ok := adjustmentKeys[key]; ok {
+		playerSettings.Adjustments.Step(adjustment.param, adjustment.steps)
+		osd.Show(playerSettings.Adjustments.Format(adjustment.param))
+		if rememberSettings {
+			saveSettings()
+		}
+	}
+	if key == glfw.KeyL && action == glfw.Press {
+		toggleLUT()
+	}
+	if key == glfw.KeyD && action == glfw.Press {
+		deinterlacer.Mode = deinterlacer.Mode.Next()
+		osd.Show(fmt.Sprintf("Deinterlacing: %v", deinterlacer.Mode))
+	}
+	if key == glfw.KeyS && action == glfw.Press {
+		scaler.Filter = scaler.Filter.Next()
+		osd.Show(fmt.Sprintf("Scaling: %v", scaler.Filter))
+	}
+	if key == glfw.KeyA && action == glfw.Press {
+		videoView.NextAspect()
+		osd.Show(fmt.Sprintf("Aspect ratio: %v", transform.FormatAspect(videoView.Aspect)))
+	}
+	if key == glfw.KeyZ && action == glfw.Press {
+		videoView.PixelPerfect = !videoView.PixelPerfect
+		if videoView.PixelPerfect {
+			osd.Show("1:1 pixels")
+		} else {
+			osd.Show("Fit to window")
+		}
+	}
+	if key == glfw.KeyF && action == glfw.Press {
+		toggleFullscreen(w)
+	}
+	if key == glfw.KeyEscape && action == glfw.Press && fullscreen.Active() {
+		toggleFullscreen(w)
+	}
+	if key == glfw.KeyC && action == glfw.Press {
+		toggleAutoCrop()
+	}
+	if key == glfw.KeyR && action == glfw.Press {
+		if mods&glfw.ModShift != 0 {
+			videoView.Rotate(-1)
+		} else {
+			videoView.Rotate(1)
+		}
+		osd.Show(fmt.Sprintf("Rotation: %v°", videoView.Rotation))
+	}
+	if key == glfw.KeyH && action == glfw.Press {
+		videoView.FlipH = !videoView.FlipH
+		osd.Show(fmt.Sprintf("Horizontal flip: %v", onOff(videoView.FlipH)))
+	}
+	if key == glfw.KeyV && action == glfw.Press {
+		videoView.FlipV = !videoView.FlipV
+		osd.Show(fmt.Sprintf("Vertical flip: %v", onOff(videoView.FlipV)))
+	}
+	if key == glfw.KeyX && action == glfw.Press {
+		videoView.Reset()
+		osd.Show("Zoom and pan reset")
+	}
+	if key == glfw.KeyT && action == glfw.Press {
+		toneMapping.Operator = toneMapping.Operator.Next()
+		if toneMapping.IsHDR() {
+			osd.Show(fmt.Sprintf("Tone mapping: %v", toneMapping.Operator))
+		} else {
+			osd.Show(fmt.Sprintf("Tone mapping: %v (video is not HDR)", toneMapping.Operator))
+		}
+	}
+	// Ctrl switches keys to the second subtitles
+	// (Shift reverses other keys, e.g. Shift+R)
+	subtitleSlot := subtitleSlots[0]
+	if mods&glfw.ModControl != 0 {
+		subtitleSlot = subtitleSlots[1]
+	}
+	if key == glfw.KeyU && action == glfw.Press {
+		toggleSubtitles(subtitleSlot)
+	}
+	if key == glfw.KeyJ && action == glfw.Press {
+		nextSubtitleTrack(subtitleSlot)
+	}
+	if key == glfw.KeyComma {
+		shiftSubtitles(subtitleSlot, -subtitleDelayStep)
+	}
+	if key == glfw.KeyPeriod {
+		shiftSubtitles(subtitleSlot, subtitleDelayStep)
+	}
+	if key == glfw.KeyBackspace && action == glfw.Press {
+		playerSettings.Adjustments.Reset()
+		osd.Show("Picture adjustments reset")
+		if rememberSettings {
+			saveSettings()
+		}
+	}
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+func toggleFullscreen(w *glfw.Window) {
+	err := fullscreen.Toggle(w)
+	if err != nil {
+		showError(err)
+		return
+	}
+	// refresh rate of the monitor may differ
+	pacer.SetRefresh(fullscreen.WindowMonitor(w).GetVideoMode().RefreshRate)
+	buttonsBar.UpdatePos()
+}
+
+func toggleAutoCrop() {
+	autoCrop = !autoCrop
+	if autoCrop {
+		cropDetector.Reset()
+	} else {
+		videoView.Crop = manualCrop
+	}
+	osd.Show(fmt.Sprintf("Black bars detection: %v", onOff(autoCrop)))
+}
+
+// Subtitle track shown with its own delay and style,
+// the second slot is used for dual subtitles (e.g. native and learnt language)
+type subtitleSlot struct {
+	title   string           // used in the messages
+	track   *subtitles.Track // nil if nothing is shown
+	visible bool
+	delay   time.Duration // positive delay shows subtitles later
+	style   subtitles.Style
+	pending int // embedded track restored from the settings, it's chosen once the tracks are read
+}
+
+// Loads subtitle files of both slots. Without --sub/--sub2 the tracks saved for the video are restored,
+// the primary one falls back to the file named like the video
+func loadSubtitles(paths [2]string) {
+	for i, slot := range subtitleSlots {
+		path := paths[i]
+		if choice := playerSettings.Subtitles[i]; path == "" && choice != nil {
+			path, slot.pending = choice.File, choice.Track
+			if slot.delay == 0 {
+				slot.delay = choice.Delay
+			}
+		}
+		if path == "" && slot.pending == 0 && i == 0 {
+			path = subtitles.FindSidecar(videoPath)
+		}
+		if path != "" {
+			slot.track = openSubtitleFile(path)
+		}
+	}
+}
+
+// Returns the loaded track of the file or loads it, nil on errors
+func openSubtitleFile(path string) *subtitles.Track {
+	if absPath, err := filepath.Abs(path); err == nil {
+		path = absPath
+	}
+	for _, track := range subtitleTracks {
+		if track.Path == path {
+			return track
+		}
+	}
+	track, err := subtitles.Load(path)
+	if err != nil {
+		showError(fmt.Errorf("Can't load subtitles: %w", err))
+		return nil
+	}
+	subtitleTracks = append(subtitleTracks, track)
+	return track
+}
+
+// Remembers tracks and delays of both slots for the video
+func saveSubtitleChoice() {
+	for i, slot := range subtitleSlots {
+		if slot.pending != 0 {
+			// restored track isn't read yet
+			continue
+		}
+		playerSettings.Subtitles[i] = nil
+		if slot.track != nil {
+			playerSettings.Subtitles[i] = &settings.SubtitleChoice{
+				File:  slot.track.Path,
+				Track: slot.track.Number,
+				Delay: slot.delay,
+			}
+		}
+	}
+	saveSettings()
+}
+
+func toggleSubtitles(slot *subtitleSlot) {
+	if slot.track == nil {
+		osd.Show(fmt.Sprintf("%s: no track is chosen", slot.title))
+		return
+	}
+	slot.visible = !slot.visible
+	osd.Show(fmt.Sprintf("%s: %v", slot.title, onOff(slot.visible)))
+}
+
+// Result of reading the subtitle tracks stored in the video file
+type embeddedTracks struct {
+	tracks []*subtitles.Track
+	err    error
+}
+
+// Adds the embedded tracks to the track list and chooses the restored ones,
+// the default track is shown if the primary slot is empty
+func addEmbeddedSubtitles(embedded embeddedTracks) {
+	if embedded.err != nil {
+		showError(fmt.Errorf("Can't read embedded subtitles: %w", embedded.err))
+		return
+	}
+	subtitleTracks = append(subtitleTracks, embedded.tracks...)
+	for _, slot := range subtitleSlots {
+		for _, track := range embedded.tracks {
+			if slot.pending != 0 && track.Number == slot.pending {
+				slot.track = track
+			}
+		}
+		slot.pending = 0
+	}
+
+	primary := subtitleSlots[0]
+	if primary.track != nil || len(embedded.tracks) == 0 {
+		return
+	}
+	primary.track = embedded.tracks[0]
+	for _, track := range embedded.tracks {
+		if track.Default {
+			primary.track = track
+			break
+		}
+	}
+}
+
+// Switches the slot to the next track. Tracks are cycled through
+// skipping the one of the other slot, the second slot is turned off after the last one
+func nextSubtitleTrack(slot *subtitleSlot) {
+	other := subtitleSlots[0]
+	if slot == other {
+		other = subtitleSlots[1]
+	}
+	var candidates []*subtitles.Track
+	for _, track := range subtitleTracks {
+		if track != other.track {
+			candidates = append(candidates, track)
+		}
+	}
+	if slot == subtitleSlots[1] {
+		candidates = append(candidates, nil)
+	}
+	if len(candidates) == 0 || (len(candidates) == 1 && candidates[0] == slot.track) {
+		osd.Show(fmt.Sprintf("%s: there are no other tracks", slot.title))
+		return
+	}
+
+	index := 0
+	for i, track := range candidates {
+		if track == slot.track {
+			index = (i + 1) % len(candidates)
+		}
+	}
+	slot.track = candidates[index]
+	slot.visible = true
+	slot.pending = 0
+	saveSubtitleChoice()
+	if slot.track == nil {
+		osd.Show(fmt.Sprintf("%s: off", slot.title))
+		return
+	}
+	osd.Show(fmt.Sprintf("%s: %s", slot.title, slot.track.Name))
+}
+
+func shiftSubtitles(slot *subtitleSlot, step time.Duration) {
+	slot.delay += step
+	saveSubtitleChoice()
+	osd.Show(fmt.Sprintf("%s delay: %+.1f s", slot.title, slot.delay.Seconds()))
+}
+
+// Draws subtitles of both slots over the visible part of the picture above the buttons bar
+func drawSubtitles(window *glfw.Window, texture *textures.VideoTexture) {
+	fbWidth, fbHeight := window.GetFramebufferSize()
+	frameWidth, frameHeight := texture.Size()
+	picture := videoView.PictureRect(fbWidth, fbHeight, frameWidth, frameHeight)
+	visible := toSubtitleArea(window, picture.Intersect(image.Rect(0, 0, fbWidth, fbHeight)))
+	if buttonsBar.GetVisibility() {
+		if bottom := buttonsBar.Top(); visible.Y+visible.Height > bottom {
+			visible.Height = bottom - visible.Y
+		}
+	}
+	for _, slot := range subtitleSlots {
+		if slot.track == nil || !slot.visible {
+			continue
+		}
+		at := getPlaybackTime() - slot.delay
+		subtitleRenderer.Draw(slot.track, at, toSubtitleArea(window, picture), visible, slot.style)
+	}
+}
+
+// Converts rectangle in framebuffer pixels to logical coordinates
+func toSubtitleArea(window *glfw.Window, rect image.Rectangle) subtitles.Area {
+	scale := buttons.ContentScale(window)
+	return subtitles.Area{
+		X:      float32(rect.Min.X) / scale,
+		Y:      float32(rect.Min.Y) / scale,
+		Width:  float32(rect.Dx()) / scale,
+		Height: float32(rect.Dy()) / scale,
+	}
+}
+
+func toggleLUT() {
+	if colorLUT == nil {
+		osd.Show("LUT is not loaded (use --lut)")
+		return
+	}
+	lutEnabled = !lutEnabled
+	osd.Show(fmt.Sprintf("LUT: %v", onOff(lutEnabled)))
+}
+
+func saveSettings() {
+	saved := *playerSettings
+	if !rememberSettings {
+		saved.Adjustments = savedAdjustments
+	}
+	err := settings.Save(videoPath, &saved)
+	if err != nil {
+		osd.ShowError(fmt.Sprintf("Can't save settings: %v", err))
+	}
 }
 
 func mouseCallback(w *glfw.Window, button glfw.MouseButton, action glfw.Action, mods glfw.ModifierKey) {
@@ -532,11 +1469,12 @@ func mouseCallback(w *glfw.Window, button glfw.MouseButton, action glfw.Action,
 	stopButton := buttonsBar.GetButton("stop")
 	scroller := buttonsBar.GetScroller()
 	soundVolume := buttonsBar.GetSoundVolume()
+	timeDisplay := buttonsBar.GetTimeDisplay()
 
 	if button == glfw.MouseButtonLeft && action == glfw.Press {
 		mouseX, mouseY := w.GetCursorPos()
-		x := float32(mouseX)
-		y := float32(mouseY)
+		// controls are laid out in logical coordinates
+		x, y := buttons.ToLogical(w, mouseX, mouseY)
 		switch {
 		case playButton.IsMouseOver(x, y) && !isPlaying:
 			playPause()
@@ -547,19 +1485,54 @@ func mouseCallback(w *glfw.Window, button glfw.MouseButton, action glfw.Action,
 		// move video to position specified by mouse click
 		// (maybe position will be set via dragging scroller button in the future)
 		case scroller.IsMouseOver(x, y):
-			scrollVideo(w, mouseX)
+			scrollVideo(w, x)
 		case soundVolume.IsMouseOver(x, y):
 			soundLevel := getSoundLevel(w, x)
 			changeSoundVolume(soundLevel)
 			buttonsBar.MoveSoundHandle(x)
+		case timeDisplay.IsMouseOver(x, y):
+			timeDisplay.Toggle()
+		case !buttonsBar.IsMouseOver(x, y):
+			if time.Since(lastClick) < doubleClickInterval {
+				toggleFullscreen(w)
+				lastClick = time.Time{}
+				break
+			}
+			lastClick = time.Now()
+			dragging = true
+			dragX, dragY = mouseX, mouseY
 		}
 	}
+	if button == glfw.MouseButtonLeft && action == glfw.Release {
+		dragging = false
+	}
+}
+
+// Pans the picture while it's dragged
+func cursorPosCallback(w *glfw.Window, x, y float64) {
+	if !dragging {
+		return
+	}
+	wWidth, wHeight := w.GetSize()
+	videoView.Pan(2*(x-dragX)/float64(wWidth), -2*(y-dragY)/float64(wHeight))
+	dragX, dragY = x, y
+}
+
+// Zooms the picture around the cursor
+func scrollCallback(w *glfw.Window, xOffset, yOffset float64) {
+	mouseX, mouseY := w.GetCursorPos()
+	wWidth, wHeight := w.GetSize()
+	x := 2*mouseX/float64(wWidth) - 1
+	y := 1 - 2*mouseY/float64(wHeight)
+	videoView.ZoomAt(math.Pow(zoomStep, yOffset), x, y)
+	osd.Show(fmt.Sprintf("Zoom: %.0f%%", videoView.Zoom*100))
 }
 
-func scrollVideo(w *glfw.Window, mouseX float64) {
-	wWidth, _ := w.GetSize()
+// x is in logical coordinates
+func scrollVideo(w *glfw.Window, x float32) {
+	wWidth, _ := buttons.LogicalSize(w)
 	// position of the scroller handle relative to window width in percents (from 0 to 1)
-	scrollerHandlePos := mouseX / float64(wWidth)
+	scrollerHandlePos := float64(x / wWidth)
 	setVideoFramesPlayed(scrollerHandlePos)
 	videoTimePos := getVideoTimePos(scrollerHandlePos)
 	rewind(videoTimePos)
@@ -586,12 +1559,36 @@ func stop() {
 
 func rewind(t time.Duration) {
 	// drain existing buffers
+	videoQueue.Purge()
+	audioQueue.Purge()
 	video.frameBuffer.Purge()
 	sampleSource.Purge()
+	mediaClock.Reset(t)
+	pacer.Reset()
 	// next command will rewind video and audio streams
 	videoStream.Rewind(t)
 }
 
+// Playback position by the media clock, the first frame is shown while stopped
+func getPlaybackTime() time.Duration {
+	if stopped {
+		return 0
+	}
+	return mediaClock.Time()
+}
+
+// Duration of the video stream or of the whole file if the stream doesn't store it,
+// 0 if both are unknown
+func mediaDuration(media *reisen.Media) time.Duration {
+	if d, err := media.VideoStreams()[0].Duration(); err == nil && d > 0 {
+		return d
+	}
+	if d, err := media.Duration(); err == nil && d > 0 {
+		return d
+	}
+	return 0
+}
+
 func getVideoProgress() float32 {
 	// To bypass moving scroller inaccuracies related to differences between screen coords and number of frames
 	if video.videoTotalFramesPlayed > video.videoTotalFrames {
@@ -624,10 +1621,11 @@ func changeSoundVolume(level float32) {
 	soundVolume.Volume = float64(0.04*level - 2)
 }
 
+// volumePos is in logical coordinates
 func getSoundLevel(w *glfw.Window, volumePos float32) float32 {
-	wWidth, _ := w.GetSize()
+	wWidth, _ := buttons.LogicalSize(w)
 	k := float32(100) / 120
-	b := float32(140-wWidth) * k
+	b := (140 - wWidth) * k
 	level := k*volumePos + b
 	return level
 }
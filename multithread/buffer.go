@@ -7,9 +7,35 @@ import (
 
 type SharedBuffer struct {
 	queue    []interface{}
-	capacity int
+	capacity int // in items or in bytes if sizeOf is set
+	used     int // sum of queued items sizes (in capacity units)
+	sizeOf   func(interface{}) int
 	opened   bool
 	mu       sync.Mutex
+
+	// instrumentation
+	peak      int
+	underruns int64 // number of reads that had to wait for data
+	overruns  int64 // number of writes that had to wait for free space
+	purges    int64
+
+	// watermarks (in capacity units), disabled when highWatermark == 0
+	lowWatermark  int
+	highWatermark int
+	onLow         func()
+	onHigh        func()
+	drained       bool // size dropped to low watermark and hasn't reached high one yet
+}
+
+// Snapshot of the buffer counters
+type BufferStats struct {
+	Size      int // in items
+	Used      int // in capacity units
+	Capacity  int
+	Peak      int // in capacity units
+	Underruns int64
+	Overruns  int64
+	Purges    int64
 }
 
 func (sb *SharedBuffer) Read() (interface{}, bool) {
@@ -18,6 +44,8 @@ func (sb *SharedBuffer) Read() (interface{}, bool) {
 	var bufSize int
 	var continueLoop = true
 	var shouldSleep = false
+	var waited = false
+	var callback func()
 	for continueLoop {
 		if shouldSleep {
 			time.Sleep(time.Millisecond)
@@ -30,31 +58,47 @@ func (sb *SharedBuffer) Read() (interface{}, bool) {
 		switch {
 		case bufSize > 1:
 			item = sb.queue[0]
+			sb.queue[0] = nil
 			sb.queue = sb.queue[1:]
+			sb.used -= sb.itemSize(item)
 			continueLoop = false
 		case bufSize == 1:
 			item = sb.queue[0]
 			if bufOpened {
-				sb.queue = make([]interface{}, 0, sb.capacity)
+				sb.queue = sb.newQueue()
 			} else {
 				sb.queue = []interface{}{}
 			}
+			sb.used = 0
 			continueLoop = false
 		case bufSize == 0 && !bufOpened:
 			continueLoop = false
 		default:
+			if !waited {
+				sb.underruns++
+				waited = true
+			}
 			shouldSleep = true
 		}
+		if !continueLoop {
+			callback = sb.checkWatermarks()
+		}
 		sb.mu.Unlock()
 	}
+	if callback != nil {
+		callback()
+	}
 	return item, bufOpened
 }
 
 func (sb *SharedBuffer) Write(elem interface{}) {
 	var bufClosed bool
 	var bufSize int
+	var elemSize = sb.itemSize(elem)
 	var continueLoop = true
 	var shouldSleep = false
+	var waited = false
+	var callback func()
 	for continueLoop {
 		if shouldSleep {
 			time.Sleep(time.Millisecond)
@@ -67,14 +111,85 @@ func (sb *SharedBuffer) Write(elem interface{}) {
 		switch {
 		case bufClosed:
 			continueLoop = false
-		case bufSize != sb.capacity:
-			sb.queue = append(sb.queue, elem)
+		// item bigger than the whole capacity is still accepted by empty buffer
+		case bufSize == 0 || sb.used+elemSize <= sb.capacity:
+			callback = sb.push(elem, elemSize)
 			continueLoop = false
 		default:
+			if !waited {
+				sb.overruns++
+				waited = true
+			}
 			shouldSleep = true
 		}
 		sb.mu.Unlock()
 	}
+	if callback != nil {
+		callback()
+	}
+}
+
+// Writes elem waiting for free space only while wait returns true
+// (it's checked every millisecond). Returns false if elem was dropped,
+// closed buffer drops nothing and returns true like TryWrite
+func (sb *SharedBuffer) WriteWhile(elem interface{}, wait func() bool) bool {
+	waited := false
+	for !sb.TryWrite(elem) {
+		if !wait() {
+			return false
+		}
+		if !waited {
+			sb.mu.Lock()
+			sb.overruns++
+			sb.mu.Unlock()
+			waited = true
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return true
+}
+
+// Returns the first item without removing it (doesn't wait).
+// Returns false if buffer is empty
+func (sb *SharedBuffer) Peek() (interface{}, bool) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	if len(sb.queue) == 0 {
+		return nil, false
+	}
+	return sb.queue[0], true
+}
+
+// Writes elem only if there is free space in the buffer (doesn't wait).
+// Returns true if elem was written or buffer is closed
+func (sb *SharedBuffer) TryWrite(elem interface{}) bool {
+	elemSize := sb.itemSize(elem)
+	var callback func()
+	written := true
+	sb.mu.Lock()
+	switch {
+	case !sb.opened:
+	case len(sb.queue) == 0 || sb.used+elemSize <= sb.capacity:
+		callback = sb.push(elem, elemSize)
+	default:
+		written = false
+	}
+	sb.mu.Unlock()
+	if callback != nil {
+		callback()
+	}
+	return written
+}
+
+// Must be called with sb.mu locked.
+// Returns watermark callback which should be called after unlocking
+func (sb *SharedBuffer) push(elem interface{}, elemSize int) func() {
+	sb.queue = append(sb.queue, elem)
+	sb.used += elemSize
+	if sb.used > sb.peak {
+		sb.peak = sb.used
+	}
+	return sb.checkWatermarks()
 }
 
 func (sb *SharedBuffer) Size() int {
@@ -84,6 +199,25 @@ func (sb *SharedBuffer) Size() int {
 	return size
 }
 
+func (sb *SharedBuffer) Capacity() int {
+	sb.mu.Lock()
+	capacity := sb.capacity
+	sb.mu.Unlock()
+	return capacity
+}
+
+// Changes buffer capacity (in capacity units) on the fly.
+// If buffer holds more than new capacity,
+// items are kept and writers wait until the queue shrinks
+func (sb *SharedBuffer) SetCapacity(capacity int) {
+	if capacity < 1 {
+		capacity = 1
+	}
+	sb.mu.Lock()
+	sb.capacity = capacity
+	sb.mu.Unlock()
+}
+
 func (sb *SharedBuffer) Close() {
 	sb.mu.Lock()
 	sb.opened = false
@@ -92,8 +226,92 @@ func (sb *SharedBuffer) Close() {
 
 func (sb *SharedBuffer) Purge() {
 	sb.mu.Lock()
-	sb.queue = make([]interface{}, 0, sb.capacity)
+	sb.queue = sb.newQueue()
+	sb.used = 0
+	sb.purges++
+	callback := sb.checkWatermarks()
+	sb.mu.Unlock()
+	if callback != nil {
+		callback()
+	}
+}
+
+func (sb *SharedBuffer) Stats() BufferStats {
+	sb.mu.Lock()
+	stats := BufferStats{
+		Size:      len(sb.queue),
+		Used:      sb.used,
+		Capacity:  sb.capacity,
+		Peak:      sb.peak,
+		Underruns: sb.underruns,
+		Overruns:  sb.overruns,
+		Purges:    sb.purges,
+	}
 	sb.mu.Unlock()
+	return stats
+}
+
+// Sets buffer fill levels (in capacity units) which trigger callbacks:
+//
+// onLow is called once size drops to low (or below),
+//
+// onHigh is called once size reaches high again.
+//
+// Callbacks are called from the goroutine which caused the change
+// (reader, writer or purger) without holding the buffer lock.
+// The callback of the current state is called right away, e.g. onLow for the empty
+// buffer of a stream which is just opened. It's called with the lock held so that
+// it can't overtake the callback of a concurrent change, it must not use the buffer.
+// Pass high = 0 to disable watermarks
+func (sb *SharedBuffer) SetWatermarks(low, high int, onLow, onHigh func()) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	sb.lowWatermark = low
+	sb.highWatermark = high
+	sb.onLow = onLow
+	sb.onHigh = onHigh
+	sb.drained = sb.used <= low
+	callback := onHigh
+	if sb.drained {
+		callback = onLow
+	}
+	if high != 0 && callback != nil {
+		callback()
+	}
+}
+
+// Must be called with sb.mu locked.
+// Returns callback which should be called after unlocking
+func (sb *SharedBuffer) checkWatermarks() func() {
+	if sb.highWatermark == 0 {
+		return nil
+	}
+	size := sb.used
+	switch {
+	// closed buffer is drained by the end of stream, not by starvation
+	case !sb.drained && size <= sb.lowWatermark && sb.opened:
+		sb.drained = true
+		return sb.onLow
+	case sb.drained && size >= sb.highWatermark:
+		sb.drained = false
+		return sb.onHigh
+	}
+	return nil
+}
+
+func (sb *SharedBuffer) itemSize(item interface{}) int {
+	if sb.sizeOf == nil {
+		return 1
+	}
+	return sb.sizeOf(item)
+}
+
+func (sb *SharedBuffer) newQueue() []interface{} {
+	// capacity in bytes says nothing about number of items
+	if sb.sizeOf != nil {
+		return []interface{}{}
+	}
+	return make([]interface{}, 0, sb.capacity)
 }
 
 func NewSharedBuffer(capacity int) *SharedBuffer {
@@ -104,3 +322,15 @@ func NewSharedBuffer(capacity int) *SharedBuffer {
 	}
 	return &sb
 }
+
+// Creates buffer which capacity is measured in bytes,
+// sizeOf returns number of bytes occupied by an item
+func NewSharedBufferBytes(capacity int, sizeOf func(interface{}) int) *SharedBuffer {
+	var sb = SharedBuffer{
+		queue:    []interface{}{},
+		capacity: capacity,
+		sizeOf:   sizeOf,
+		opened:   true,
+	}
+	return &sb
+}
@@ -0,0 +1,192 @@
+package multithread
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWriteWhileDropsWhenNotWaiting(t *testing.T) {
+	sb := NewSharedBuffer(1)
+	sb.Write("first")
+	if sb.WriteWhile("second", func() bool { return false }) {
+		t.Fatal("item is written into full buffer")
+	}
+	if sb.Size() != 1 {
+		t.Errorf("size %d, want 1", sb.Size())
+	}
+	if stats := sb.Stats(); stats.Overruns != 0 {
+		t.Errorf("%d overruns of dropped write, want 0", stats.Overruns)
+	}
+}
+
+func TestWriteWhileWaitsForReader(t *testing.T) {
+	sb := NewSharedBuffer(1)
+	sb.Write("first")
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		sb.Read()
+	}()
+	if !sb.WriteWhile("second", func() bool { return true }) {
+		t.Fatal("item is dropped while waiting is allowed")
+	}
+	item, _ := sb.Read()
+	if item != "second" {
+		t.Errorf("read %v, want second", item)
+	}
+	if stats := sb.Stats(); stats.Overruns != 1 {
+		t.Errorf("%d overruns, want 1", stats.Overruns)
+	}
+}
+
+// Waiting stops as soon as the condition fails (e.g. audio queue runs dry)
+func TestWriteWhileStopsWaiting(t *testing.T) {
+	sb := NewSharedBuffer(1)
+	sb.Write("first")
+	checks := 0
+	written := sb.WriteWhile("second", func() bool {
+		checks++
+		return checks < 3
+	})
+	if written {
+		t.Fatal("item is written into full buffer")
+	}
+	if checks != 3 {
+		t.Errorf("condition checked %d times, want 3", checks)
+	}
+}
+
+func TestWriteWhileClosed(t *testing.T) {
+	sb := NewSharedBuffer(1)
+	sb.Write("first")
+	sb.Close()
+	if !sb.WriteWhile("second", func() bool { return false }) {
+		t.Error("closed buffer reports dropped item")
+	}
+}
+
+// Records watermark callbacks, true - onLow, false - onHigh
+type watermarkLog []bool
+
+func (log *watermarkLog) watch(sb *SharedBuffer, low, high int) {
+	sb.SetWatermarks(low, high,
+		func() { *log = append(*log, true) },
+		func() { *log = append(*log, false) },
+	)
+}
+
+func (log watermarkLog) equal(want ...bool) bool {
+	if len(log) != len(want) {
+		return false
+	}
+	for i := range log {
+		if log[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Empty buffer of a stream which is just opened reports buffering right away
+func TestSetWatermarksInitialState(t *testing.T) {
+	var log watermarkLog
+	log.watch(NewSharedBuffer(10), 0, 3)
+	if !log.equal(true) {
+		t.Errorf("callbacks %v, want onLow", log)
+	}
+
+	sb := NewSharedBuffer(10)
+	sb.Write(1)
+	sb.Write(2)
+	log = nil
+	log.watch(sb, 1, 3)
+	if !log.equal(false) {
+		t.Errorf("callbacks %v of filled buffer, want onHigh", log)
+	}
+
+	log = nil
+	log.watch(NewSharedBuffer(10), 0, 0)
+	if len(log) != 0 {
+		t.Errorf("callbacks %v of disabled watermarks", log)
+	}
+}
+
+func TestWatermarkTransitions(t *testing.T) {
+	sb := NewSharedBuffer(10)
+	var log watermarkLog
+	log.watch(sb, 1, 3)
+
+	sb.Write(1)
+	sb.Write(2)
+	if !log.equal(true) {
+		t.Fatalf("callbacks %v below high watermark, want initial onLow only", log)
+	}
+	sb.Write(3)
+	if !log.equal(true, false) {
+		t.Fatalf("callbacks %v at high watermark", log)
+	}
+	sb.Write(4)
+	sb.Read()
+	sb.Read()
+	if !log.equal(true, false) {
+		t.Fatalf("callbacks %v above low watermark", log)
+	}
+	sb.Read()
+	if !log.equal(true, false, true) {
+		t.Fatalf("callbacks %v at low watermark", log)
+	}
+	// hysteresis: nothing between the watermarks
+	sb.Write(5)
+	sb.Read()
+	if !log.equal(true, false, true) {
+		t.Fatalf("callbacks %v while refilling", log)
+	}
+	sb.Write(6)
+	sb.Write(7)
+	sb.Write(8)
+	sb.Purge()
+	if !log.equal(true, false, true, false, true) {
+		t.Errorf("callbacks %v after refill and purge", log)
+	}
+}
+
+// End of stream doesn't report buffering
+func TestWatermarksClosed(t *testing.T) {
+	sb := NewSharedBuffer(10)
+	sb.Write(1)
+	sb.Write(2)
+	var log watermarkLog
+	log.watch(sb, 0, 2)
+	sb.Close()
+	sb.Read()
+	sb.Read()
+	if !log.equal(false) {
+		t.Errorf("callbacks %v, want initial onHigh only", log)
+	}
+}
+
+func TestStatsCounters(t *testing.T) {
+	sb := NewSharedBufferBytes(10, func(item interface{}) int { return item.(int) })
+	sb.Write(4)
+	sb.Write(5)
+	if sb.TryWrite(2) {
+		t.Fatal("item is written over the capacity")
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		sb.Read()
+	}()
+	sb.Write(2)
+	sb.Purge()
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		sb.Write(3)
+	}()
+	sb.Read()
+
+	stats := sb.Stats()
+	want := BufferStats{Size: 0, Used: 0, Capacity: 10, Peak: 9, Underruns: 1, Overruns: 1, Purges: 1}
+	if stats != want {
+		t.Errorf("stats %+v, want %+v", stats, want)
+	}
+}
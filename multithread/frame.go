@@ -0,0 +1,42 @@
+package multithread
+
+import (
+	"image"
+	"image/draw"
+	"time"
+)
+
+// Decoded video frame
+type Frame struct {
+	Image *image.RGBA
+	Pts   time.Duration // presentation time
+}
+
+// Makes frame from the decoded image.
+// RGBA images are taken as is: the decoder allocates new image for every frame,
+// so the frame owns it and nothing is copied.
+// Images of other formats are converted to RGBA.
+// Returns nil if image pixels don't match its size
+func NewFrame(img image.Image, pts time.Duration) *Frame {
+	if rgba, ok := img.(*image.RGBA); ok {
+		size := rgba.Rect.Size()
+		if size.X <= 0 || size.Y <= 0 || len(rgba.Pix) < rgba.Stride*(size.Y-1)+4*size.X {
+			return nil
+		}
+		return &Frame{Image: rgba, Pts: pts}
+	}
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Rect, img, bounds.Min, draw.Src)
+	return &Frame{Image: rgba, Pts: pts}
+}
+
+// Size of the frame image in bytes,
+// can be used as sizeOf function of the frame buffer
+func FrameSize(item interface{}) int {
+	frame, ok := item.(*Frame)
+	if !ok {
+		return 0
+	}
+	return len(frame.Image.Pix)
+}
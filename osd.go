@@ -0,0 +1,61 @@
+package main
+
+import (
+	"time"
+	"videoplayer/text"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const (
+	osdDuration   = 2 * time.Second
+	errorDuration = 5 * time.Second
+	osdMargin     = 10 // distance from the window edges in logical pixels
+)
+
+// Short on-screen messages (e.g. values of the changed settings) and errors,
+// drawn in the top left corner of the window
+type OSD struct {
+	message string
+	until   time.Time
+	isError bool
+}
+
+func (osd *OSD) Show(message string) {
+	osd.message = message
+	osd.until = time.Now().Add(osdDuration)
+	osd.isError = false
+}
+
+// Shows error message, it stays longer than the regular ones
+func (osd *OSD) ShowError(message string) {
+	osd.message = message
+	osd.until = time.Now().Add(errorDuration)
+	osd.isError = true
+}
+
+// Current message, empty if it has expired
+func (osd *OSD) Message() string {
+	if time.Now().After(osd.until) {
+		return ""
+	}
+	return osd.message
+}
+
+// Draws the current message and buffering state (in the top right corner)
+func (osd *OSD) Draw(r *text.Renderer, buffering bool) {
+	style := text.DefaultStyle()
+	if message := osd.Message(); message != "" {
+		messageStyle := style
+		if osd.isError {
+			messageStyle.Color = mgl32.Vec4{1, 0.4, 0.35, 1}
+		}
+		r.Draw(message, osdMargin, osdMargin, messageStyle)
+	}
+	if buffering {
+		const label = "Buffering..."
+		width, _ := r.Size()
+		labelWidth, _ := r.Measure(label, style)
+		r.Draw(label, width-labelWidth-osdMargin, osdMargin, style)
+	}
+}
@@ -0,0 +1,130 @@
+package pacing
+
+// Presentation pacing synchronized with display refresh.
+//
+// Render loop runs once per vblank (swap interval 1),
+// pacer measures actual refresh period from swap timestamps
+// and keeps smooth display timeline (media time of the next vblank)
+// locked to the jittery media clock
+
+import (
+	"time"
+)
+
+const (
+	// display timeline is moved to the clock at once if it's too far away (e.g. after rewind)
+	resyncThreshold = 200 * time.Millisecond
+	// part of the timeline error corrected on each vblank
+	correctionGain = 0.05
+	// weight of the new swap interval in refresh period estimation
+	refreshGain = 0.05
+
+	minRefresh = time.Second / 360
+	maxRefresh = time.Second / 20
+)
+
+type Pacer struct {
+	refresh       time.Duration // estimated refresh period
+	frameDuration time.Duration // nominal video frame duration
+	pulldown      bool
+	lastSwap      time.Duration // timestamp of the last buffer swap, 0 if unknown
+	timeline      time.Duration // media time of the next vblank
+	synced        bool
+}
+
+// Creates pacer with initial refresh period (e.g. monitor video mode refresh rate).
+//
+// In pulldown mode the display timeline isn't corrected
+// until it drifts away from the clock for more than a frame duration,
+// so frames are held for consistent number of vblanks (e.g. 3:2 for 24 fps on 60 Hz)
+func New(refresh, frameDuration time.Duration, pulldown bool) *Pacer {
+	return &Pacer{
+		refresh:       clampRefresh(refresh),
+		frameDuration: frameDuration,
+		pulldown:      pulldown,
+	}
+}
+
+// Should be called right after buffer swap with the current time
+func (p *Pacer) Swapped(t time.Duration) {
+	if p.lastSwap != 0 {
+		interval := t - p.lastSwap
+		// missed vblanks and spurious early swaps don't tell anything about refresh period
+		if interval > p.refresh/2 && interval < p.refresh*3/2 {
+			p.refresh = clampRefresh(p.refresh + time.Duration(refreshGain*float64(interval-p.refresh)))
+		}
+	}
+	p.lastSwap = t
+}
+
+// Estimated display refresh period
+func (p *Pacer) Refresh() time.Duration {
+	return p.refresh
+}
+
+// Moves display timeline by one vblank and returns media time at which
+// the frame rendered now will be displayed.
+//
+// clock is the current media time, should be called once per rendered frame while playing
+func (p *Pacer) Advance(clock time.Duration) time.Duration {
+	target := clock + p.refresh
+	if !p.synced {
+		p.timeline = target
+		p.synced = true
+		return p.timeline
+	}
+
+	p.timeline += p.refresh
+	drift := target - p.timeline
+	switch {
+	case drift > resyncThreshold || drift < -resyncThreshold:
+		p.timeline = target
+	case p.pulldown:
+		if drift > p.frameDuration || drift < -p.frameDuration {
+			p.timeline = target
+		}
+	default:
+		p.timeline += time.Duration(correctionGain * float64(drift))
+	}
+	return p.timeline
+}
+
+// Sets refresh period by display refresh rate in Hz (e.g. after switching to other monitor),
+// non-positive rates are ignored.
+// Swap interval estimation and display timeline start over
+func (p *Pacer) SetRefresh(rate int) {
+	if rate <= 0 {
+		return
+	}
+	p.refresh = clampRefresh(time.Second / time.Duration(rate))
+	p.lastSwap = 0
+	p.synced = false
+}
+
+// Forces display timeline to be taken from the clock on the next Advance
+// (e.g. after rewind or pause)
+func (p *Pacer) Reset() {
+	p.synced = false
+}
+
+// Reports whether frame with pts should be shown at the vblank
+// with the displayTime returned by Advance
+func (p *Pacer) Due(pts, displayTime time.Duration) bool {
+	if p.pulldown {
+		// frame is shown starting from the first vblank after its pts,
+		// which keeps the cadence regular
+		return pts <= displayTime
+	}
+	// vblank closest to the frame pts
+	return pts < displayTime+p.refresh/2
+}
+
+func clampRefresh(refresh time.Duration) time.Duration {
+	switch {
+	case refresh < minRefresh:
+		return minRefresh
+	case refresh > maxRefresh:
+		return maxRefresh
+	}
+	return refresh
+}
@@ -0,0 +1,88 @@
+package picture
+
+// Deinterlacing done by the video shader
+
+import (
+	"fmt"
+	"time"
+	"videoplayer/container"
+	"videoplayer/shaders"
+)
+
+type DeinterlaceMode int
+
+const (
+	DeinterlaceOff      DeinterlaceMode = iota
+	DeinterlaceBob                      // each field is shown separately, missing lines are interpolated
+	DeinterlaceAdaptive                 // still areas are woven from both fields, moving ones are bobbed
+	deinterlaceModeCount
+)
+
+var deinterlaceNames = []string{"off", "bob", "adaptive"}
+
+func (m DeinterlaceMode) String() string {
+	return deinterlaceNames[m]
+}
+
+// Next mode, wraps around
+func (m DeinterlaceMode) Next() DeinterlaceMode {
+	return (m + 1) % deinterlaceModeCount
+}
+
+type Deinterlacer struct {
+	Mode     DeinterlaceMode
+	TopFirst bool // top field is displayed first
+}
+
+// Parses mode name, "auto" picks adaptive mode for video flagged interlaced
+// by the container and turns deinterlacing off otherwise
+// (H.264 parameters allowing fields don't mean the frames are interlaced)
+func ParseDeinterlaceMode(name string, info *container.VideoInfo) (DeinterlaceMode, error) {
+	if name == "auto" {
+		switch info.FieldOrder {
+		case container.Interlaced, container.TopFieldFirst, container.BottomFieldFirst:
+			return DeinterlaceAdaptive, nil
+		}
+		return DeinterlaceOff, nil
+	}
+	for i, n := range deinterlaceNames {
+		if n == name {
+			return DeinterlaceMode(i), nil
+		}
+	}
+	return DeinterlaceOff, fmt.Errorf("unknown deinterlace mode: %s", name)
+}
+
+// Parses field order name, "auto" takes it from the stream metadata
+// (top field first if it's unknown, as in most HD material)
+func ParseFieldOrder(name string, info *container.VideoInfo) (bool, error) {
+	switch name {
+	case "auto":
+		return info.FieldOrder != container.BottomFieldFirst, nil
+	case "tff":
+		return true, nil
+	case "bff":
+		return false, nil
+	}
+	return true, fmt.Errorf("unknown field order: %s", name)
+}
+
+func (d *Deinterlacer) Enabled() bool {
+	return d.Mode != DeinterlaceOff
+}
+
+// Field shown at the display time: 0 - top, 1 - bottom.
+// Fields of the frame are shown for half of its duration each
+func (d *Deinterlacer) Field(pts, displayTime, frameDuration time.Duration) int32 {
+	second := displayTime-pts >= frameDuration/2
+	if d.TopFirst != second {
+		return 0
+	}
+	return 1
+}
+
+// Sets deinterlacing uniforms of the video shader
+func (d *Deinterlacer) Apply(sh uint32, field int32) {
+	shaders.SetInt(sh, "deinterlace", int32(d.Mode))
+	shaders.SetInt(sh, "field", field)
+}
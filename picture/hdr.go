@@ -0,0 +1,135 @@
+package picture
+
+// HDR -> SDR conversion done by the video shader
+
+import (
+	"fmt"
+	"videoplayer/container"
+	"videoplayer/shaders"
+)
+
+// Transfer function of the video signal
+type Transfer int
+
+const (
+	TransferAuto Transfer = iota // taken from the stream metadata
+	TransferSDR                  // BT.709/sRGB gamma, no conversion
+	TransferPQ                   // HDR10, SMPTE ST 2084
+	TransferHLG                  // hybrid log-gamma, ARIB STD-B67
+)
+
+type Primaries int
+
+const (
+	PrimariesAuto Primaries = iota
+	PrimariesBT709
+	PrimariesBT2020
+)
+
+// Operator compressing HDR luminance into SDR range
+type ToneMap int
+
+const (
+	ToneMapClip ToneMap = iota
+	ToneMapReinhard
+	ToneMapHable
+	ToneMapBT2390
+	toneMapCount
+)
+
+var transferNames = map[string]Transfer{
+	"auto": TransferAuto,
+	"sdr":  TransferSDR,
+	"pq":   TransferPQ,
+	"hlg":  TransferHLG,
+}
+
+var primariesNames = map[string]Primaries{
+	"auto":   PrimariesAuto,
+	"bt709":  PrimariesBT709,
+	"bt2020": PrimariesBT2020,
+}
+
+var toneMapNames = []string{"clip", "reinhard", "hable", "bt2390"}
+
+func ParseTransfer(name string) (Transfer, error) {
+	t, ok := transferNames[name]
+	if !ok {
+		return TransferAuto, fmt.Errorf("unknown transfer function: %s", name)
+	}
+	return t, nil
+}
+
+func ParsePrimaries(name string) (Primaries, error) {
+	p, ok := primariesNames[name]
+	if !ok {
+		return PrimariesAuto, fmt.Errorf("unknown colour primaries: %s", name)
+	}
+	return p, nil
+}
+
+func ParseToneMap(name string) (ToneMap, error) {
+	for i, n := range toneMapNames {
+		if n == name {
+			return ToneMap(i), nil
+		}
+	}
+	return ToneMapClip, fmt.Errorf("unknown tone mapping operator: %s", name)
+}
+
+func (tm ToneMap) String() string {
+	return toneMapNames[tm]
+}
+
+// Next operator, wraps around
+func (tm ToneMap) Next() ToneMap {
+	return (tm + 1) % toneMapCount
+}
+
+// Parameters of HDR -> SDR conversion
+type ToneMapping struct {
+	Transfer  Transfer
+	Primaries Primaries
+	Operator  ToneMap
+	Peak      float32 // peak luminance of the content in nits
+}
+
+// White level of SDR output in nits (BT.2408 reference white)
+const sdrWhite = 203
+
+// Replaces auto values with the ones from the stream metadata
+func (tm *ToneMapping) Resolve(info *container.VideoInfo) {
+	if tm.Transfer == TransferAuto {
+		switch info.ColorTransfer {
+		case container.TransferPQ:
+			tm.Transfer = TransferPQ
+		case container.TransferHLG:
+			tm.Transfer = TransferHLG
+		default:
+			tm.Transfer = TransferSDR
+		}
+	}
+	if tm.Primaries == PrimariesAuto {
+		tm.Primaries = PrimariesBT709
+		if info.ColorPrimaries == container.PrimariesBT2020 {
+			tm.Primaries = PrimariesBT2020
+		}
+	}
+}
+
+func (tm *ToneMapping) IsHDR() bool {
+	return tm.Transfer == TransferPQ || tm.Transfer == TransferHLG
+}
+
+// Sets tone mapping uniforms of the video shader
+func (tm *ToneMapping) Apply(sh uint32) {
+	transfer := tm.Transfer
+	if transfer == TransferAuto {
+		transfer = TransferSDR
+	}
+	shaders.SetInt(sh, "transfer", int32(transfer)-int32(TransferSDR))
+	shaders.SetBool(sh, "bt2020", tm.Primaries == PrimariesBT2020)
+	shaders.SetInt(sh, "toneMap", int32(tm.Operator))
+	shaders.SetFloat(sh, "hdrPeak", tm.Peak)
+	shaders.SetFloat(sh, "sdrWhite", sdrWhite)
+}
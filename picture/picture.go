@@ -0,0 +1,113 @@
+package picture
+
+// Picture adjustments applied by the video shader
+
+import (
+	"fmt"
+	"videoplayer/shaders"
+)
+
+type Param int
+
+const (
+	Brightness Param = iota
+	Contrast
+	Saturation
+	Hue
+	Gamma
+)
+
+type Adjustments struct {
+	Brightness float32 `json:"brightness"` // added to every component, [-1, 1]
+	Contrast   float32 `json:"contrast"`   // [0, 3]
+	Saturation float32 `json:"saturation"` // [0, 3]
+	Hue        float32 `json:"hue"`        // rotation in degrees, [-180, 180]
+	Gamma      float32 `json:"gamma"`      // [0.1, 5]
+}
+
+type paramInfo struct {
+	name     string
+	step     float32
+	min, max float32
+	neutral  float32
+}
+
+var params = map[Param]paramInfo{
+	Brightness: {"Brightness", 0.02, -1, 1, 0},
+	Contrast:   {"Contrast", 0.05, 0, 3, 1},
+	Saturation: {"Saturation", 0.05, 0, 3, 1},
+	Hue:        {"Hue", 5, -180, 180, 0},
+	Gamma:      {"Gamma", 0.05, 0.1, 5, 1},
+}
+
+// Adjustments which don't change the picture
+func Default() Adjustments {
+	return Adjustments{
+		Contrast:   1,
+		Saturation: 1,
+		Gamma:      1,
+	}
+}
+
+func (p Param) String() string {
+	return params[p].name
+}
+
+func (a *Adjustments) Get(p Param) float32 {
+	return *a.field(p)
+}
+
+// Sets parameter value clamped to its range
+func (a *Adjustments) Set(p Param, value float32) {
+	info := params[p]
+	switch {
+	case value < info.min:
+		value = info.min
+	case value > info.max:
+		value = info.max
+	}
+	*a.field(p) = value
+}
+
+// Changes parameter by number of steps (negative steps decrease it)
+func (a *Adjustments) Step(p Param, steps int) {
+	a.Set(p, a.Get(p)+float32(steps)*params[p].step)
+}
+
+func (a *Adjustments) Reset() {
+	*a = Default()
+}
+
+// Human readable value of the parameter e.g. "Contrast: 1.10"
+func (a *Adjustments) Format(p Param) string {
+	if p == Hue {
+		return fmt.Sprintf("%s: %+.0f°", p, a.Hue)
+	}
+	if p == Brightness {
+		return fmt.Sprintf("%s: %+.2f", p, a.Brightness)
+	}
+	return fmt.Sprintf("%s: %.2f", p, a.Get(p))
+}
+
+// Sets uniforms of the video shader
+func (a *Adjustments) Apply(sh uint32) {
+	shaders.SetFloat(sh, "brightness", a.Brightness)
+	shaders.SetFloat(sh, "contrast", a.Contrast)
+	shaders.SetFloat(sh, "saturation", a.Saturation)
+	shaders.SetFloat(sh, "hue", a.Hue)
+	shaders.SetFloat(sh, "gamma", a.Gamma)
+}
+
+func (a *Adjustments) field(p Param) *float32 {
+	switch p {
+	case Brightness:
+		return &a.Brightness
+	case Contrast:
+		return &a.Contrast
+	case Saturation:
+		return &a.Saturation
+	case Hue:
+		return &a.Hue
+	}
+	return &a.Gamma
+}
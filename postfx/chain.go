@@ -0,0 +1,191 @@
+package postfx
+
+// Chain of user defined fragment shaders applied to the rendered picture.
+//
+// The picture is rendered into a framebuffer object,
+// then every pass draws full screen quad sampling output of the previous one,
+// the last pass draws into the window.
+//
+// Each pass gets uniforms:
+//
+//	sampler2D texImage — output of the previous pass
+//	vec2 resolution — output size in pixels
+//	float time — seconds since the chain creation
+//	int frame — number of the rendered frame
+//
+// Shader files are watched and recompiled on change,
+// pass which fails to compile keeps its previous version (or is skipped)
+
+import (
+	"fmt"
+	"os"
+	"time"
+	"videoplayer/shaders"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+type Pass struct {
+	path    string
+	program uint32 // 0 - pass has never been compiled successfully
+	modTime time.Time
+	err     error
+}
+
+type Chain struct {
+	passes       []*Pass
+	vertexSource string
+	targets      [2]Target // ping-pong between passes
+	current      int       // target the picture is rendered into
+	width        int32
+	height       int32
+	start        time.Time
+	frame        int32
+	active       bool // Begin was called and chain had passes to apply
+}
+
+// Loads and compiles fragment shaders of the passes,
+// compile errors are returned by Errors
+func NewChain(vertexPath string, fragmentPaths []string) (*Chain, error) {
+	vertexSource, err := os.ReadFile(vertexPath)
+	if err != nil {
+		return nil, err
+	}
+	chain := &Chain{
+		vertexSource: string(vertexSource),
+		start:        time.Now(),
+	}
+	for _, path := range fragmentPaths {
+		pass := &Pass{path: path}
+		pass.load(chain.vertexSource)
+		chain.passes = append(chain.passes, pass)
+	}
+	return chain, nil
+}
+
+// Errors of the passes which failed to load
+func (c *Chain) Errors() []error {
+	var errs []error
+	for _, pass := range c.passes {
+		if pass.err != nil {
+			errs = append(errs, pass.err)
+		}
+	}
+	return errs
+}
+
+// Recompiles passes which files have changed since the last load.
+// Returns errors of the recompiled passes
+func (c *Chain) Reload() []error {
+	var errs []error
+	for _, pass := range c.passes {
+		info, err := os.Stat(pass.path)
+		if err != nil || info.ModTime().Equal(pass.modTime) {
+			continue
+		}
+		if pass.load(c.vertexSource) {
+			continue
+		}
+		errs = append(errs, pass.err)
+	}
+	return errs
+}
+
+// Reports whether there is at least one pass to apply
+func (c *Chain) Enabled() bool {
+	for _, pass := range c.passes {
+		if pass.program != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Redirects rendering into the chain input (or into the window if there are no passes),
+// picture size is the window framebuffer size
+func (c *Chain) Begin(width, height int32) {
+	c.active = c.Enabled()
+	c.width, c.height = width, height
+	if !c.active {
+		gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+		gl.Viewport(0, 0, width, height)
+		return
+	}
+	c.targets[0].Resize(width, height)
+	c.targets[1].Resize(width, height)
+	c.current = 0
+	c.targets[c.current].Bind()
+	gl.ClearColor(0.0, 0.0, 0.0, 1.0)
+	gl.Clear(gl.COLOR_BUFFER_BIT)
+}
+
+// Applies the passes, the last one renders into the window.
+// vao should hold full screen quad with positions (location 0)
+// and texture coordinates (location 1)
+func (c *Chain) Apply(vao uint32) {
+	if !c.active {
+		return
+	}
+	c.active = false
+
+	var passes []*Pass
+	for _, pass := range c.passes {
+		if pass.program != 0 {
+			passes = append(passes, pass)
+		}
+	}
+
+	resolution := mgl32.Vec2{float32(c.width), float32(c.height)}
+	elapsed := float32(time.Since(c.start).Seconds())
+	gl.BindVertexArray(vao)
+	for i, pass := range passes {
+		input := c.targets[c.current].Texture()
+		if i == len(passes)-1 {
+			gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+			gl.Viewport(0, 0, c.width, c.height)
+		} else {
+			c.current = 1 - c.current
+			c.targets[c.current].Bind()
+		}
+
+		shaders.Use(pass.program)
+		gl.ActiveTexture(gl.TEXTURE0)
+		gl.BindTexture(gl.TEXTURE_2D, input)
+		shaders.SetInt(pass.program, "texImage", 0)
+		shaders.SetVec2(pass.program, "resolution", &resolution)
+		shaders.SetFloat(pass.program, "time", elapsed)
+		shaders.SetInt(pass.program, "frame", c.frame)
+		gl.DrawArrays(gl.TRIANGLES, 0, 6)
+	}
+	gl.BindVertexArray(0)
+	c.frame++
+}
+
+// Returns false if the pass failed to load, previous program is kept then
+func (p *Pass) load(vertexSource string) bool {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		p.err = err
+		return false
+	}
+	p.modTime = info.ModTime()
+
+	fragmentSource, err := os.ReadFile(p.path)
+	if err != nil {
+		p.err = err
+		return false
+	}
+	program, err := shaders.Compile(vertexSource, string(fragmentSource))
+	if err != nil {
+		p.err = fmt.Errorf("%s: %w", p.path, err)
+		return false
+	}
+
+	if p.program != 0 {
+		gl.DeleteProgram(p.program)
+	}
+	p.program = program
+	p.err = nil
+	return true
+}
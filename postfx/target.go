@@ -0,0 +1,52 @@
+package postfx
+
+import (
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// Framebuffer object rendering into a texture
+type Target struct {
+	fbo     uint32
+	texture uint32
+	width   int32
+	height  int32
+}
+
+// Allocates (or reallocates on size change) the texture of the target
+func (t *Target) Resize(width, height int32) {
+	if t.fbo != 0 && t.width == width && t.height == height {
+		return
+	}
+	if t.fbo == 0 {
+		gl.GenFramebuffers(1, &t.fbo)
+		gl.GenTextures(1, &t.texture)
+	}
+
+	gl.BindTexture(gl.TEXTURE_2D, t.texture)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA8, width, height, 0, gl.RGBA, gl.UNSIGNED_BYTE, nil)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+
+	gl.BindFramebuffer(gl.FRAMEBUFFER, t.fbo)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, t.texture, 0)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+
+	t.width = width
+	t.height = height
+}
+
+// Makes target the destination of the following draw calls
+func (t *Target) Bind() {
+	gl.BindFramebuffer(gl.FRAMEBUFFER, t.fbo)
+	gl.Viewport(0, 0, t.width, t.height)
+}
+
+func (t *Target) Texture() uint32 {
+	return t.texture
+}
+
+func (t *Target) Size() (int32, int32) {
+	return t.width, t.height
+}
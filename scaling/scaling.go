@@ -0,0 +1,150 @@
+package scaling
+
+// Scaling of the video picture to the window.
+// Video shader renders the picture at frame resolution first,
+// then it's resampled by hardware filtering or by two separable passes
+// (horizontal into intermediate target, vertical into the window)
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"videoplayer/postfx"
+	"videoplayer/shaders"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+type Filter int
+
+const (
+	Nearest Filter = iota
+	Bilinear
+	Bicubic
+	Lanczos
+	filterCount
+)
+
+var filterNames = []string{"nearest", "bilinear", "bicubic", "lanczos"}
+
+func ParseFilter(name string) (Filter, error) {
+	for i, n := range filterNames {
+		if n == name {
+			return Filter(i), nil
+		}
+	}
+	return Bilinear, fmt.Errorf("unknown scaling filter: %s", name)
+}
+
+func (f Filter) String() string {
+	return filterNames[f]
+}
+
+// Next filter, wraps around
+func (f Filter) Next() Filter {
+	return (f + 1) % filterCount
+}
+
+func (f Filter) separable() bool {
+	return f == Bicubic || f == Lanczos
+}
+
+// max width of the intermediate target
+const maxTargetSize = 8192
+
+type Scaler struct {
+	Filter     Filter
+	program    uint32
+	source     postfx.Target // picture at frame resolution
+	horizontal postfx.Target // source scaled horizontally
+	outWidth   int32
+	outHeight  int32
+}
+
+func New(vertexPath, fragmentPath string, filter Filter) *Scaler {
+	return &Scaler{
+		Filter:  filter,
+		program: shaders.New(vertexPath, fragmentPath),
+	}
+}
+
+// Size of the picture in pixels drawn with the view matrix into the framebuffer,
+// measured along the picture axes
+func OutputSize(view mgl32.Mat4, fbWidth, fbHeight int) (int32, int32) {
+	// the quad is [-1, 1] square, so its half axes are the matrix columns
+	width := math.Hypot(float64(view[0])*float64(fbWidth), float64(view[1])*float64(fbHeight))
+	height := math.Hypot(float64(view[4])*float64(fbWidth), float64(view[5])*float64(fbHeight))
+	return int32(math.Round(width)), int32(math.Round(height))
+}
+
+// Redirects rendering into the source target of the frame size,
+// video should be drawn into it without any transformation
+func (s *Scaler) Begin(frameWidth, frameHeight int32) {
+	s.source.Resize(frameWidth, frameHeight)
+	s.source.Bind()
+	gl.ClearColor(0.0, 0.0, 0.0, 1.0)
+	gl.Clear(gl.COLOR_BUFFER_BIT)
+}
+
+// Runs the first pass of separable filters for the output size
+func (s *Scaler) Prepare(vao uint32, outWidth, outHeight int32) {
+	// zoomed in picture is magnified by the last pass
+	if outWidth > maxTargetSize {
+		outWidth = maxTargetSize
+	}
+	s.outWidth, s.outHeight = outWidth, outHeight
+	if !s.Filter.separable() || outWidth <= 0 || outHeight <= 0 {
+		return
+	}
+	sourceWidth, sourceHeight := s.source.Size()
+	s.horizontal.Resize(outWidth, sourceHeight)
+	s.horizontal.Bind()
+
+	identity := mgl32.Ident4()
+	s.draw(vao, s.source.Texture(), &identity, mgl32.Vec2{1, 0}, float32(outWidth)/float32(sourceWidth))
+}
+
+// Draws the scaled picture into the bound framebuffer,
+// everything outside of the clip rectangle (in framebuffer pixels) is cut off
+func (s *Scaler) Draw(vao uint32, view *mgl32.Mat4, clip image.Rectangle) {
+	gl.Enable(gl.SCISSOR_TEST)
+	gl.Scissor(int32(clip.Min.X), int32(clip.Min.Y), int32(clip.Dx()), int32(clip.Dy()))
+	defer gl.Disable(gl.SCISSOR_TEST)
+
+	if !s.Filter.separable() {
+		filter := int32(gl.LINEAR)
+		if s.Filter == Nearest {
+			filter = gl.NEAREST
+		}
+		gl.BindTexture(gl.TEXTURE_2D, s.source.Texture())
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, filter)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, filter)
+		s.draw(vao, s.source.Texture(), view, mgl32.Vec2{}, 1)
+		return
+	}
+	_, sourceHeight := s.source.Size()
+	s.draw(vao, s.horizontal.Texture(), view, mgl32.Vec2{0, 1}, float32(s.outHeight)/float32(sourceHeight))
+}
+
+func (s *Scaler) draw(vao, input uint32, view *mgl32.Mat4, direction mgl32.Vec2, scale float32) {
+	kernel := int32(0)
+	switch s.Filter {
+	case Bicubic:
+		kernel = 1
+	case Lanczos:
+		kernel = 2
+	}
+
+	shaders.Use(s.program)
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, input)
+	shaders.SetInt(s.program, "texImage", 0)
+	shaders.SetInt(s.program, "kernel", kernel)
+	shaders.SetVec2(s.program, "direction", &direction)
+	shaders.SetFloat(s.program, "scale", scale)
+	shaders.SetMat4(s.program, "view", view)
+	gl.BindVertexArray(vao)
+	gl.DrawArrays(gl.TRIANGLES, 0, 6)
+	gl.BindVertexArray(0)
+}
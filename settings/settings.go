@@ -0,0 +1,86 @@
+package settings
+
+// Per-file player settings kept in the user config directory
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+	"videoplayer/picture"
+)
+
+// Subtitle track chosen for the file
+type SubtitleChoice struct {
+	File  string        `json:"file,omitempty"`  // absolute path of the subtitle file
+	Track int           `json:"track,omitempty"` // number of the embedded track if there is no file
+	Delay time.Duration `json:"delay,omitempty"`
+}
+
+type Settings struct {
+	Adjustments picture.Adjustments `json:"adjustments"`
+	// primary and secondary subtitles, nil if the track isn't chosen
+	Subtitles [2]*SubtitleChoice `json:"subtitles"`
+}
+
+func Default() *Settings {
+	return &Settings{
+		Adjustments: picture.Default(),
+	}
+}
+
+// Loads settings saved for the video file,
+// returns default settings if there are no saved ones
+func Load(videoPath string) (*Settings, error) {
+	path, err := settingsPath(videoPath)
+	if err != nil {
+		return Default(), err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return Default(), nil
+	}
+	if err != nil {
+		return Default(), err
+	}
+
+	// fields missing in the file keep default values
+	s := Default()
+	err = json.Unmarshal(data, s)
+	if err != nil {
+		return Default(), err
+	}
+	return s, nil
+}
+
+func Save(videoPath string, s *Settings) error {
+	path, err := settingsPath(videoPath)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Settings file is named by hash of the absolute video path
+func settingsPath(videoPath string) (string, error) {
+	absPath, err := filepath.Abs(videoPath)
+	if err != nil {
+		return "", err
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	hash := sha1.Sum([]byte(absPath))
+	return filepath.Join(configDir, "videoplayer", "files", hex.EncodeToString(hash[:])+".json"), nil
+}
@@ -0,0 +1,23 @@
+#version 410
+// Scanlines, slight barrel distortion and vignette
+out vec4 FragmentColor;
+
+in vec2 TexCoord;
+uniform sampler2D texImage;
+uniform vec2 resolution;
+uniform float time;
+
+void main() {
+  vec2 uv = TexCoord * 2.0 - 1.0;
+  uv *= 1.0 + 0.03 * dot(uv, uv);
+  uv = uv * 0.5 + 0.5;
+  if (uv.x < 0.0 || uv.x > 1.0 || uv.y < 0.0 || uv.y > 1.0) {
+    FragmentColor = vec4(0.0, 0.0, 0.0, 1.0);
+    return;
+  }
+
+  vec3 color = texture(texImage, uv).rgb;
+  float scanline = 0.85 + 0.15 * sin(uv.y * resolution.y * 3.14159 + time * 2.0);
+  float vignette = 1.0 - 0.3 * dot(uv - 0.5, uv - 0.5) * 4.0;
+  FragmentColor = vec4(color * scanline * vignette, 1.0);
+}
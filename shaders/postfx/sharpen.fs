@@ -0,0 +1,21 @@
+#version 410
+// Unsharp mask
+out vec4 FragmentColor;
+
+in vec2 TexCoord;
+uniform sampler2D texImage;
+uniform vec2 resolution;
+
+const float amount = 0.6;
+
+void main() {
+  vec2 texel = 1.0 / resolution;
+  vec3 center = texture(texImage, TexCoord).rgb;
+  vec3 blur = (
+    texture(texImage, TexCoord + vec2(texel.x, 0.0)).rgb +
+    texture(texImage, TexCoord - vec2(texel.x, 0.0)).rgb +
+    texture(texImage, TexCoord + vec2(0.0, texel.y)).rgb +
+    texture(texImage, TexCoord - vec2(0.0, texel.y)).rgb
+  ) / 4.0;
+  FragmentColor = vec4(clamp(center + amount * (center - blur), 0.0, 1.0), 1.0);
+}
@@ -0,0 +1,69 @@
+#version 410
+out vec4 FragmentColor;
+
+in vec2 TexCoord;
+uniform sampler2D texImage;
+uniform int kernel;     // 0 - hardware filtering, 1 - bicubic, 2 - Lanczos
+uniform vec2 direction; // (1, 0) - horizontal pass, (0, 1) - vertical pass
+uniform float scale;    // output size / input size along the direction
+
+const float PI = 3.14159265;
+const int maxTaps = 32;
+
+// Mitchell-Netravali filter (B = C = 1/3)
+float bicubic(float x) {
+  const float B = 1.0 / 3.0;
+  const float C = 1.0 / 3.0;
+  x = abs(x);
+  if (x < 1.0) {
+    return ((12.0 - 9.0 * B - 6.0 * C) * x * x * x +
+      (-18.0 + 12.0 * B + 6.0 * C) * x * x + (6.0 - 2.0 * B)) / 6.0;
+  }
+  if (x < 2.0) {
+    return ((-B - 6.0 * C) * x * x * x + (6.0 * B + 30.0 * C) * x * x +
+      (-12.0 * B - 48.0 * C) * x + (8.0 * B + 24.0 * C)) / 6.0;
+  }
+  return 0.0;
+}
+
+// Lanczos filter with 3 lobes
+float lanczos(float x) {
+  const float a = 3.0;
+  x = abs(x);
+  if (x < 1e-5) {
+    return 1.0;
+  }
+  if (x >= a) {
+    return 0.0;
+  }
+  float px = PI * x;
+  return a * sin(px) * sin(px / a) / (px * px);
+}
+
+void main() {
+  if (kernel == 0) {
+    FragmentColor = vec4(texture(texImage, TexCoord).rgb, 1.0);
+    return;
+  }
+
+  float size = dot(vec2(textureSize(texImage, 0)), direction);
+  // position in texels along the direction, texel centres are at integers
+  float position = dot(TexCoord, direction) * size - 0.5;
+  float radius = kernel == 1 ? 2.0 : 3.0;
+  // kernel is stretched on downscaling to avoid aliasing
+  float stretch = max(1.0 / scale, 1.0);
+  int taps = min(int(ceil(radius * stretch)), maxTaps);
+
+  vec3 sum = vec3(0.0);
+  float weights = 0.0;
+  float first = floor(position) - float(taps - 1);
+  for (int i = 0; i < 2 * taps; i++) {
+    float texel = first + float(i);
+    float x = (position - texel) / stretch;
+    float weight = kernel == 1 ? bicubic(x) : lanczos(x);
+    vec2 coord = TexCoord * (1.0 - direction) + direction * (texel + 0.5) / size;
+    sum += texture(texImage, coord).rgb * weight;
+    weights += weight;
+  }
+  FragmentColor = vec4(clamp(sum / weights, 0.0, 1.0), 1.0);
+}
@@ -15,20 +15,35 @@ func New(
 	vertexPath,
 	fragmetPath string,
 ) uint32 {
-	var vertexCode, fragmentCode string
+	shaderID, err := Load(vertexPath, fragmetPath)
+	if err != nil {
+		panic(err)
+	}
+	return shaderID
+}
 
+// Same as New but returns error instead of panicking
+func Load(
+	vertexPath,
+	fragmetPath string,
+) (uint32, error) {
 	vShaderFileBuf, err := os.ReadFile(vertexPath)
 	if err != nil {
-		panic(err)
+		return 0, err
 	}
 
 	fShaderFileBuf, err := os.ReadFile(fragmetPath)
 	if err != nil {
-		panic(err)
+		return 0, err
 	}
 
-	vertexCode = string(vShaderFileBuf) + "\x00"
-	fragmentCode = string(fShaderFileBuf) + "\x00"
+	return Compile(string(vShaderFileBuf), string(fShaderFileBuf))
+}
+
+// Compiles and links shader program from the source code
+func Compile(vertexSource, fragmentSource string) (uint32, error) {
+	vertexCode := vertexSource + "\x00"
+	fragmentCode := fragmentSource + "\x00"
 
 	var vertex, fragment uint32
 	vertex = gl.CreateShader(gl.VERTEX_SHADER)
@@ -36,9 +51,10 @@ func New(
 	gl.ShaderSource(vertex, 1, vShaderCode, nil)
 	free()
 	gl.CompileShader(vertex)
-	err = checkCompileErrors(vertex, "VERTEX")
+	defer gl.DeleteShader(vertex)
+	err := checkCompileErrors(vertex, "VERTEX")
 	if err != nil {
-		panic(err)
+		return 0, err
 	}
 
 	fragment = gl.CreateShader(gl.FRAGMENT_SHADER)
@@ -47,9 +63,10 @@ func New(
 	gl.ShaderSource(fragment, 1, fShaderCode, nil)
 	free()
 	gl.CompileShader(fragment)
+	defer gl.DeleteShader(fragment)
 	err = checkCompileErrors(fragment, "FRAGMENT")
 	if err != nil {
-		panic(err)
+		return 0, err
 	}
 
 	shaderID := gl.CreateProgram()
@@ -58,9 +75,10 @@ func New(
 	gl.LinkProgram(shaderID)
 	err = checkCompileErrors(shaderID, "PROGRAM")
 	if err != nil {
-		panic(err)
+		gl.DeleteProgram(shaderID)
+		return 0, err
 	}
-	return shaderID
+	return shaderID, nil
 }
 
 func Use(shaderId uint32) {
@@ -83,6 +101,14 @@ func SetFloat(shID uint32, name string, value float32) {
 	gl.Uniform1f(gl.GetUniformLocation(shID, gl.Str(name+"\x00")), value)
 }
 
+func SetVec2(shID uint32, name string, value *mgl32.Vec2) {
+	gl.Uniform2fv(gl.GetUniformLocation(shID, gl.Str(name+"\x00")), 1, &value[0])
+}
+
+func SetVec3(shID uint32, name string, value *mgl32.Vec3) {
+	gl.Uniform3fv(gl.GetUniformLocation(shID, gl.Str(name+"\x00")), 1, &value[0])
+}
+
 func SetVec4(shID uint32, name string, value *mgl32.Vec4) {
 	gl.Uniform4fv(gl.GetUniformLocation(shID, gl.Str(name+"\x00")), 1, &value[0])
 }
@@ -105,7 +131,7 @@ func checkCompileErrors(shader uint32, shaderType string) error {
 
 			gl.GetShaderInfoLog(shader, 1024, nil, gl.Str(infoLog))
 
-			return fmt.Errorf("Shader compilation error of type: %s\n %s", shaderType, infoLog)
+			return fmt.Errorf("Shader compilation error of type: %s\n %s", shaderType, strings.TrimRight(infoLog, "\x00"))
 		}
 	} else {
 		gl.GetProgramiv(shader, gl.LINK_STATUS, &success)
@@ -115,7 +141,7 @@ func checkCompileErrors(shader uint32, shaderType string) error {
 
 			gl.GetProgramInfoLog(shader, 1024, nil, gl.Str(infoLog))
 
-			return fmt.Errorf("Program linking error of type: %s\n %s", shaderType, infoLog)
+			return fmt.Errorf("Program linking error of type: %s\n %s", shaderType, strings.TrimRight(infoLog, "\x00"))
 		}
 	}
 	return nil
@@ -0,0 +1,57 @@
+#version 410
+out vec4 FragmentColor;
+
+in vec2 TexPos;
+
+uniform sampler2D atlas; // glyph coverage
+uniform float atlasSize;
+uniform vec4 color;
+uniform vec4 outlineColor;
+uniform float outline;   // outline width in pixels, 0 - no outline
+uniform float embolden;  // synthetic bold in pixels
+uniform bool shadow;     // shadow pass: glyph with its outline filled with color
+
+float coverage(vec2 pos) {
+  return texture(atlas, pos / atlasSize).r;
+}
+
+// glyph coverage, widened to the right for synthetic bold
+float glyph(vec2 pos) {
+  float alpha = coverage(pos);
+  if (embolden > 0.0) {
+    alpha = max(alpha, coverage(pos - vec2(embolden * 0.5, 0.0)));
+    alpha = max(alpha, coverage(pos - vec2(embolden, 0.0)));
+  }
+  return alpha;
+}
+
+// glyph coverage grown by the outline width
+float dilated(vec2 pos) {
+  const int directions = 16;
+  float alpha = glyph(pos);
+  for (int i = 0; i < directions; i++) {
+    float angle = 6.2831853 * float(i) / float(directions);
+    vec2 direction = vec2(cos(angle), sin(angle));
+    alpha = max(alpha, glyph(pos + direction * outline));
+    alpha = max(alpha, glyph(pos + direction * outline * 0.5));
+  }
+  return alpha;
+}
+
+void main() {
+  float fill = glyph(TexPos);
+  float edge = outline > 0.0 ? dilated(TexPos) : fill;
+  if (shadow) {
+    FragmentColor = vec4(color.rgb, color.a * edge);
+    return;
+  }
+
+  // fill is drawn over the outline
+  float fillAlpha = fill * color.a;
+  float edgeAlpha = edge * outlineColor.a * (1.0 - fillAlpha);
+  float alpha = fillAlpha + edgeAlpha;
+  if (alpha <= 0.0) {
+    discard;
+  }
+  FragmentColor = vec4((color.rgb * fillAlpha + outlineColor.rgb * edgeAlpha) / alpha, alpha);
+}
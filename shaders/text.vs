@@ -0,0 +1,15 @@
+#version 410
+layout (location = 0) in vec2 aPos;     // framebuffer pixels, (0, 0) - top left corner
+layout (location = 1) in vec2 aTexPos;  // atlas pixels
+
+out vec2 TexPos;
+
+uniform vec2 resolution; // framebuffer size
+uniform vec2 offset;     // shadow offset in pixels
+
+void main()
+{
+    vec2 pos = (aPos + offset) / resolution;
+    gl_Position = vec4(pos.x * 2.0 - 1.0, 1.0 - pos.y * 2.0, 0.0, 1.0);
+    TexPos = aTexPos;
+}
@@ -4,6 +4,255 @@ out vec4 FragmentColor;
 in vec2 TexCoord;
 uniform sampler2D texImage;
 
+// deinterlacing
+uniform int deinterlace; // 0 - off, 1 - bob, 2 - motion adaptive
+uniform int field;       // field to show: 0 - top (even lines), 1 - bottom
+uniform sampler2D texPrev; // previous frame
+
+// HDR -> SDR conversion
+uniform int transfer;   // 0 - SDR, 1 - PQ, 2 - HLG
+uniform bool bt2020;    // BT.2020 primaries (BT.709 otherwise)
+uniform int toneMap;    // 0 - clip, 1 - Reinhard, 2 - Hable, 3 - BT.2390
+uniform float hdrPeak;  // peak luminance of the content in nits
+uniform float sdrWhite; // luminance shown as SDR white in nits
+
+// colour lookup table
+uniform bool lutEnabled;
+uniform bool lut3D;
+uniform sampler3D lut3d;
+uniform sampler1D lut1d;
+uniform float lutSize;
+uniform vec3 lutDomainMin;
+uniform vec3 lutDomainMax;
+uniform bool lutShaper; // 1D table (in lut1d) applied before the 3D one
+uniform float lutShaperSize;
+uniform vec3 lutShaperDomainMin;
+uniform vec3 lutShaperDomainMax;
+
+// picture adjustments
+uniform float brightness; // 0 - unchanged
+uniform float contrast;   // 1 - unchanged
+uniform float saturation; // 1 - unchanged
+uniform float hue;        // rotation in degrees
+uniform float gamma;      // 1 - unchanged
+
+const vec3 lumaBT709 = vec3(0.2126, 0.7152, 0.0722);
+const vec3 lumaBT2020 = vec3(0.2627, 0.6780, 0.0593);
+
+// columns of linear BT.2020 -> BT.709 RGB conversion
+const mat3 bt2020ToBT709 = mat3(
+  1.6605, -0.1246, -0.0182,
+  -0.5876, 1.1329, -0.1006,
+  -0.0728, -0.0083, 1.1187
+);
+
+// SMPTE ST 2084 constants
+const float pqM1 = 0.1593017578125;
+const float pqM2 = 78.84375;
+const float pqC1 = 0.8359375;
+const float pqC2 = 18.8515625;
+const float pqC3 = 18.6875;
+
+// PQ signal -> luminance in nits
+float pqToNits(float e) {
+  float p = pow(max(e, 0.0), 1.0 / pqM2);
+  return 10000.0 * pow(max(p - pqC1, 0.0) / (pqC2 - pqC3 * p), 1.0 / pqM1);
+}
+
+vec3 pqToNits(vec3 e) {
+  return vec3(pqToNits(e.r), pqToNits(e.g), pqToNits(e.b));
+}
+
+float nitsToPQ(float nits) {
+  float y = pow(max(nits, 0.0) / 10000.0, pqM1);
+  return pow((pqC1 + pqC2 * y) / (1.0 + pqC3 * y), pqM2);
+}
+
+// HLG signal -> display luminance in nits (display peak is hdrPeak)
+vec3 hlgToNits(vec3 e) {
+  const float a = 0.17883277;
+  const float b = 0.28466892;
+  const float c = 0.55991073;
+  vec3 low = e * e / 3.0;
+  vec3 high = (exp((e - c) / a) + b) / 12.0;
+  vec3 scene = mix(low, high, step(0.5, e));
+
+  // OOTF, system gamma depends on the display peak
+  float systemGamma = 1.2 + 0.42 * log(hdrPeak / 1000.0) / log(10.0);
+  float luma = dot(scene, bt2020 ? lumaBT2020 : lumaBT709);
+  return hdrPeak * pow(max(luma, 1e-6), systemGamma - 1.0) * scene;
+}
+
+float hable(float x) {
+  const float A = 0.15, B = 0.50, C = 0.10, D = 0.20, E = 0.02, F = 0.30;
+  return (x * (A * x + C * B) + D * E) / (x * (A * x + B) + D * F) - E / F;
+}
+
+// ITU-R BT.2390 EETF, knee spline in PQ domain
+float bt2390(float nits) {
+  float sourcePeak = nitsToPQ(hdrPeak);
+  float e = nitsToPQ(nits) / sourcePeak;
+  float maxLum = nitsToPQ(sdrWhite) / sourcePeak;
+  float ks = 1.5 * maxLum - 0.5;
+  if (e > ks && ks < 1.0) {
+    float t = (e - ks) / (1.0 - ks);
+    float t2 = t * t;
+    float t3 = t2 * t;
+    e = (2.0 * t3 - 3.0 * t2 + 1.0) * ks + (t3 - 2.0 * t2 + t) * (1.0 - ks) +
+      (-2.0 * t3 + 3.0 * t2) * maxLum;
+  }
+  return pqToNits(e * sourcePeak);
+}
+
+// Maps luminance in nits to [0, 1] SDR range,
+// the largest component is mapped and others are scaled with it to keep the hue
+vec3 toneMapNits(vec3 nits) {
+  float peak = max(max(nits.r, nits.g), nits.b);
+  if (peak <= 0.0) {
+    return vec3(0.0);
+  }
+  float x = peak / sdrWhite;
+  float white = max(hdrPeak / sdrWhite, 1.0);
+  float y;
+  if (toneMap == 1) {
+    y = x * (1.0 + x / (white * white)) / (1.0 + x);
+  } else if (toneMap == 2) {
+    const float exposure = 2.0;
+    y = hable(x * exposure) / hable(white * exposure);
+  } else if (toneMap == 3) {
+    y = bt2390(peak) / sdrWhite;
+  } else {
+    y = x;
+  }
+  return nits / sdrWhite * (min(y, 1.0) / x);
+}
+
+// Brings out of gamut colours back by desaturating them towards their luma
+vec3 gamutMap(vec3 color) {
+  float luma = dot(color, lumaBT709);
+  float minimum = min(min(color.r, color.g), color.b);
+  if (minimum < 0.0 && luma > 0.0) {
+    color = mix(vec3(luma), color, luma / (luma - minimum));
+  }
+  return clamp(color, 0.0, 1.0);
+}
+
+// Converts HDR or wide gamut signal to SDR BT.709 one,
+// SDR signal is linearized and encoded back with BT.1886 gamma 2.4
+vec3 toSDR(vec3 color) {
+  if (transfer == 0 && !bt2020) {
+    return color;
+  }
+
+  vec3 light;
+  if (transfer == 1) {
+    light = toneMapNits(pqToNits(color));
+  } else if (transfer == 2) {
+    light = toneMapNits(hlgToNits(color));
+  } else {
+    light = pow(color, vec3(2.4));
+  }
+  if (bt2020) {
+    light = gamutMap(bt2020ToBT709 * light);
+  }
+  return pow(light, vec3(1.0 / 2.4));
+}
+
+// Colour of the frame line, filtered horizontally only
+vec3 lineColor(bool previous, float x, int line, float height) {
+  vec2 coord = vec2(x, (float(line) + 0.5) / height);
+  if (previous) {
+    return texture(texPrev, coord).rgb;
+  }
+  return texture(texImage, coord).rgb;
+}
+
+float maxComponent(vec3 v) {
+  return max(max(v.r, v.g), v.b);
+}
+
+// Lines of the shown field are taken as is,
+// lines of the other field are interpolated (bob)
+// or woven from the other field where the picture doesn't move (motion adaptive)
+vec3 deinterlaced(vec2 coord) {
+  int height = textureSize(texImage, 0).y;
+  int line = clamp(int(coord.y * float(height)), 0, height - 1);
+  if ((line & 1) == field) {
+    return lineColor(false, coord.x, line, float(height));
+  }
+
+  int above = line > 0 ? line - 1 : line + 1;
+  int below = line < height - 1 ? line + 1 : line - 1;
+  vec3 colorAbove = lineColor(false, coord.x, above, float(height));
+  vec3 colorBelow = lineColor(false, coord.x, below, float(height));
+  vec3 spatial = (colorAbove + colorBelow) * 0.5;
+  if (deinterlace == 1) {
+    return spatial;
+  }
+
+  vec3 woven = lineColor(false, coord.x, line, float(height));
+  float motion = max(
+    maxComponent(abs(woven - lineColor(true, coord.x, line, float(height)))),
+    max(
+      maxComponent(abs(colorAbove - lineColor(true, coord.x, above, float(height)))),
+      maxComponent(abs(colorBelow - lineColor(true, coord.x, below, float(height))))
+    )
+  );
+  return mix(woven, spatial, smoothstep(0.02, 0.08, motion));
+}
+
+// Texture coordinates of the table entries for color in the table domain
+vec3 lutCoord(vec3 color, vec3 domainMin, vec3 domainMax, float size) {
+  vec3 x = clamp((color - domainMin) / (domainMax - domainMin), 0.0, 1.0);
+  // table entries are at the texel centres
+  return x * (size - 1.0) / size + 0.5 / size;
+}
+
+vec3 apply1D(vec3 coord) {
+  return vec3(
+    texture(lut1d, coord.r).r,
+    texture(lut1d, coord.g).g,
+    texture(lut1d, coord.b).b
+  );
+}
+
+vec3 applyLUT(vec3 color) {
+  if (lutShaper) {
+    color = apply1D(lutCoord(color, lutShaperDomainMin, lutShaperDomainMax, lutShaperSize));
+  }
+  vec3 coord = lutCoord(color, lutDomainMin, lutDomainMax, lutSize);
+  if (lut3D) {
+    // linear filtering of 3D texture gives trilinear interpolation
+    return texture(lut3d, coord).rgb;
+  }
+  return apply1D(coord);
+}
+
+vec3 adjust(vec3 color) {
+  color = (color - 0.5) * contrast + 0.5 + brightness;
+
+  float luma = dot(color, lumaBT709);
+  color = mix(vec3(luma), color, saturation);
+
+  // rotation around the grey axis
+  const vec3 grey = vec3(0.57735);
+  float angle = radians(hue);
+  color = color * cos(angle) + cross(grey, color) * sin(angle) +
+    grey * dot(grey, color) * (1.0 - cos(angle));
+
+  return pow(clamp(color, 0.0, 1.0), vec3(1.0 / gamma));
+}
+
 void main() {
-  FragmentColor = texture(texImage, TexCoord);
+  vec3 color;
+  if (deinterlace != 0) {
+    color = deinterlaced(TexCoord);
+  } else {
+    color = texture(texImage, TexCoord).rgb;
+  }
+  color = toSDR(color);
+  if (lutEnabled) {
+    color = applyLUT(color);
+  }
+  FragmentColor = vec4(adjust(color), 1.0);
 }
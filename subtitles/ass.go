@@ -0,0 +1,577 @@
+package subtitles
+
+// Advanced SubStation Alpha (.ass) and SubStation Alpha (.ssa) subtitles.
+//
+// Supported: styles (size, colours, bold/italic, outline, shadow, alignment, margins),
+// override tags \b \i \fs \c \1c-\4c \alpha \1a-\4a \bord \shad \an \a \pos \move
+// \fad \fade \k \K \kf \ko \r, line breaks \N \n \h.
+// Font names are ignored (the player font is used), drawings (\p) are skipped,
+// fill sweeps of \kf/\K syllables are shown as a switch in the middle of the syllable
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// script resolution if it's not specified (SSA default)
+const (
+	defaultScriptWidth  = 384
+	defaultScriptHeight = 288
+)
+
+var defaultStyleFormat = []string{
+	"name", "fontname", "fontsize", "primarycolour", "secondarycolour", "outlinecolour",
+	"backcolour", "bold", "italic", "underline", "strikeout", "scalex", "scaley", "spacing",
+	"angle", "borderstyle", "outline", "shadow", "alignment", "marginl", "marginr", "marginv", "encoding",
+}
+
+var defaultEventFormat = []string{
+	"layer", "start", "end", "style", "name", "marginl", "marginr", "marginv", "effect", "text",
+}
+
+type assStyle struct {
+	format    Format
+	bold      bool
+	italic    bool
+	alignment int
+	margins   [3]float32
+}
+
+func defaultASSStyle() *assStyle {
+	return &assStyle{
+		format: Format{
+			FontSize:     18,
+			Color:        mgl32.Vec4{1, 1, 1, 1},
+			KaraokeColor: mgl32.Vec4{1, 0, 0, 1},
+			OutlineColor: mgl32.Vec4{0, 0, 0, 1},
+			ShadowColor:  mgl32.Vec4{0, 0, 0, 0.5},
+			Outline:      2,
+			Shadow:       2,
+		},
+		alignment: 2,
+		margins:   [3]float32{10, 10, 10},
+	}
+}
+
+type assParser struct {
+	script       Script
+	styles       map[string]*assStyle
+	styleFormat  []string
+	eventFormat  []string
+	legacyAlign  bool // SSA numbering of alignments
+	section      string
+	track        *Track
+	defaultStyle *assStyle
+}
+
+func newASSParser() *assParser {
+	return &assParser{
+		styles:       make(map[string]*assStyle),
+		styleFormat:  defaultStyleFormat,
+		eventFormat:  defaultEventFormat,
+		track:        &Track{},
+		defaultStyle: defaultASSStyle(),
+	}
+}
+
+func ParseASS(r io.Reader) (*Track, error) {
+	text, err := readText(r)
+	if err != nil {
+		return nil, err
+	}
+	p := newASSParser()
+	var lineErr error // error of the first broken event
+	for i, line := range strings.Split(text, "\n") {
+		// broken event is skipped
+		err := p.parseLine(strings.TrimSpace(line))
+		if err != nil && lineErr == nil {
+			lineErr = fmt.Errorf("line %d: %w", i+1, err)
+		}
+	}
+	if len(p.track.Cues) == 0 {
+		if lineErr != nil {
+			return nil, lineErr
+		}
+		return nil, fmt.Errorf("no subtitles found")
+	}
+	p.track.Script = p.resolution()
+	sortCues(p.track.Cues)
+	return p.track, nil
+}
+
+func (p *assParser) parseLine(line string) error {
+	if line == "" || strings.HasPrefix(line, ";") {
+		return nil
+	}
+	if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+		p.section = strings.ToLower(line[1 : len(line)-1])
+		p.legacyAlign = p.legacyAlign || p.section == "v4 styles"
+		return nil
+	}
+	colon := strings.IndexByte(line, ':')
+	if colon < 0 {
+		return nil
+	}
+	key := strings.ToLower(strings.TrimSpace(line[:colon]))
+	value := strings.TrimSpace(line[colon+1:])
+
+	switch p.section {
+	case "script info":
+		switch key {
+		case "playresx":
+			p.script.Width = parseFloat(value)
+		case "playresy":
+			p.script.Height = parseFloat(value)
+		case "scripttype":
+			p.legacyAlign = strings.EqualFold(value, "v4.00")
+		}
+	case "v4 styles", "v4+ styles":
+		switch key {
+		case "format":
+			p.styleFormat = parseFormat(value)
+		case "style":
+			style, name := p.parseStyle(value)
+			p.styles[name] = style
+		}
+	case "events":
+		switch key {
+		case "format":
+			p.eventFormat = parseFormat(value)
+		case "dialogue":
+			cue, err := p.parseDialogue(value)
+			if err != nil {
+				return err
+			}
+			if cue != nil {
+				p.track.Cues = append(p.track.Cues, cue)
+			}
+		}
+	}
+	return nil
+}
+
+// Script resolution, missing dimension is derived from the other one (4:3)
+func (p *assParser) resolution() *Script {
+	script := p.script
+	switch {
+	case script.Width <= 0 && script.Height <= 0:
+		script = Script{Width: defaultScriptWidth, Height: defaultScriptHeight}
+	case script.Width <= 0:
+		script.Width = script.Height * 4 / 3
+		if script.Height == 1024 {
+			script.Width = 1280 // the only exception made by VSFilter
+		}
+	case script.Height <= 0:
+		script.Height = script.Width * 3 / 4
+		if script.Width == 1280 {
+			script.Height = 1024
+		}
+	}
+	return &script
+}
+
+func parseFormat(value string) []string {
+	fields := strings.Split(value, ",")
+	for i, field := range fields {
+		fields[i] = strings.ToLower(strings.TrimSpace(field))
+	}
+	return fields
+}
+
+// Splits comma separated values by the format, the last field takes the rest of the line
+func splitFields(value string, format []string) map[string]string {
+	values := strings.SplitN(value, ",", len(format))
+	fields := make(map[string]string, len(format))
+	for i, name := range format {
+		if i < len(values) {
+			fields[name] = strings.TrimSpace(values[i])
+		}
+	}
+	return fields
+}
+
+func (p *assParser) parseStyle(value string) (*assStyle, string) {
+	fields := splitFields(value, p.styleFormat)
+	style := defaultASSStyle()
+	f := &style.format
+	if size := parseFloat(fields["fontsize"]); size > 0 {
+		f.FontSize = size
+	}
+	setColor(&f.Color, fields["primarycolour"], true)
+	setColor(&f.KaraokeColor, fields["secondarycolour"], true)
+	setColor(&f.OutlineColor, fields["outlinecolour"], true)
+	setColor(&f.ShadowColor, fields["backcolour"], true)
+	style.bold = parseFlag(fields["bold"])
+	style.italic = parseFlag(fields["italic"])
+	if value, ok := fields["outline"]; ok {
+		f.Outline = parseFloat(value)
+	}
+	if value, ok := fields["shadow"]; ok {
+		f.Shadow = parseFloat(value)
+	}
+	if alignment, err := strconv.Atoi(fields["alignment"]); err == nil {
+		style.alignment = p.alignment(alignment, p.legacyAlign)
+	}
+	for i, name := range []string{"marginl", "marginr", "marginv"} {
+		if value, ok := fields[name]; ok {
+			style.margins[i] = parseFloat(value)
+		}
+	}
+	return style, strings.TrimPrefix(fields["name"], "*")
+}
+
+func (p *assParser) style(name string) *assStyle {
+	if style, ok := p.styles[strings.TrimPrefix(name, "*")]; ok {
+		return style
+	}
+	if style, ok := p.styles["Default"]; ok {
+		return style
+	}
+	return p.defaultStyle
+}
+
+// Converts SSA alignment (1-3 bottom, 5-7 top, 9-11 middle) to the numeric keypad one
+func (p *assParser) alignment(value int, legacy bool) int {
+	if legacy {
+		switch {
+		case value >= 9 && value <= 11:
+			return value - 5
+		case value >= 5 && value <= 7:
+			return value + 2
+		case value >= 1 && value <= 3:
+			return value
+		}
+		return 2
+	}
+	if value < 1 || value > 9 {
+		return 2
+	}
+	return value
+}
+
+func (p *assParser) parseDialogue(value string) (*Cue, error) {
+	fields := splitFields(value, p.eventFormat)
+	start, err := parseTimestamp(fields["start"])
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseTimestamp(fields["end"])
+	if err != nil {
+		return nil, err
+	}
+	return p.dialogueCue(fields, start, end), nil
+}
+
+// Cue of the event fields, nil if the event is never shown
+func (p *assParser) dialogueCue(fields map[string]string, start, end time.Duration) *Cue {
+	if end <= start {
+		return nil
+	}
+	style := p.style(fields["style"])
+	cue := &Cue{
+		Start:   start,
+		End:     end,
+		Margins: style.margins,
+	}
+	cue.Layer, _ = strconv.Atoi(fields["layer"])
+	// zero margins of the event mean margins of the style
+	for i, name := range []string{"marginl", "marginr", "marginv"} {
+		if margin := parseFloat(fields[name]); margin != 0 {
+			cue.Margins[i] = margin
+		}
+	}
+	p.parseText(cue, fields["text"], style)
+	if cue.Alignment == 0 {
+		cue.Alignment = style.alignment
+	}
+	return cue
+}
+
+// Splits the event text into spans applying override tags
+func (p *assParser) parseText(cue *Cue, text string, style *assStyle) {
+	state := &spanState{format: style.format, bold: style.bold, italic: style.italic}
+	var karaoke time.Duration // start of the next syllable
+	current := strings.Builder{}
+
+	flush := func() {
+		if current.Len() == 0 {
+			return
+		}
+		format := state.format
+		cue.Spans = append(cue.Spans, Span{
+			Text:   current.String(),
+			Bold:   state.bold,
+			Italic: state.italic,
+			Format: &format,
+		})
+		current.Reset()
+	}
+
+	for len(text) > 0 {
+		switch {
+		case text[0] == '{':
+			end := strings.IndexByte(text, '}')
+			if end < 0 {
+				// unterminated block is the text itself
+				if !state.drawing {
+					current.WriteString(text)
+				}
+				text = ""
+				continue
+			}
+			flush()
+			for _, tag := range splitTags(text[1:end]) {
+				p.applyTag(cue, state, tag, style, &karaoke)
+			}
+			text = text[end+1:]
+		case strings.HasPrefix(text, `\N`):
+			current.WriteString("\n")
+			text = text[2:]
+		case strings.HasPrefix(text, `\n`), strings.HasPrefix(text, `\h`):
+			// soft line breaks are not kept, lines aren't wrapped
+			current.WriteString(" ")
+			text = text[2:]
+		default:
+			size := 1
+			if i := strings.IndexAny(text[1:], `{\`); i >= 0 {
+				size += i
+			} else {
+				size = len(text)
+			}
+			if !state.drawing {
+				current.WriteString(text[:size])
+			}
+			text = text[size:]
+		}
+	}
+	flush()
+}
+
+// Formatting of the text being parsed
+type spanState struct {
+	format  Format
+	bold    bool
+	italic  bool
+	drawing bool // \p mode, text is drawing commands
+}
+
+// Splits the override block into tags without backslashes, e.g. ["b1", "pos(10,20)"]
+func splitTags(block string) []string {
+	var tags []string
+	depth := 0
+	start := -1
+	for i, char := range block {
+		switch char {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case '\\':
+			if depth > 0 {
+				continue
+			}
+			if start >= 0 {
+				tags = append(tags, block[start:i])
+			}
+			start = i + 1
+		}
+	}
+	if start >= 0 {
+		tags = append(tags, block[start:])
+	}
+	return tags
+}
+
+// Splits tag into name and argument: "fs20" -> "fs", "20"; "1c&HFF&" -> "1c", "&HFF&"
+func tagName(tag string) (string, string) {
+	if tag == "" {
+		return "", ""
+	}
+	if tag[0] >= '1' && tag[0] <= '4' && len(tag) >= 2 {
+		return tag[:2], tag[2:]
+	}
+	if tag[0] == 'r' {
+		return "r", tag[1:]
+	}
+	i := 0
+	for i < len(tag) && (tag[i] >= 'a' && tag[i] <= 'z' || tag[i] >= 'A' && tag[i] <= 'Z') {
+		i++
+	}
+	return tag[:i], strings.TrimSpace(tag[i:])
+}
+
+// Arguments of the tag in parentheses: "(10,20)" -> ["10", "20"]
+func tagArgs(arg string) []string {
+	arg = strings.TrimSpace(arg)
+	if !strings.HasPrefix(arg, "(") {
+		return nil
+	}
+	arg = strings.TrimSuffix(strings.TrimPrefix(arg, "("), ")")
+	args := strings.Split(arg, ",")
+	for i := range args {
+		args[i] = strings.TrimSpace(args[i])
+	}
+	return args
+}
+
+func (p *assParser) applyTag(cue *Cue, state *spanState, tag string, style *assStyle, karaoke *time.Duration) {
+	name, arg := tagName(tag)
+	f := &state.format
+	switch name {
+	case "b":
+		// weights (e.g. 700) are allowed besides 0/1
+		weight, err := strconv.Atoi(arg)
+		state.bold = err != nil && style.bold || err == nil && (weight == 1 || weight >= 700)
+	case "i":
+		state.italic = arg == "" && style.italic || arg == "1"
+	case "fs":
+		if size := parseFloat(arg); size > 0 {
+			f.FontSize = size
+		} else {
+			f.FontSize = style.format.FontSize
+		}
+	case "c", "1c":
+		setColor(&f.Color, arg, false)
+	case "2c":
+		setColor(&f.KaraokeColor, arg, false)
+	case "3c":
+		setColor(&f.OutlineColor, arg, false)
+	case "4c":
+		setColor(&f.ShadowColor, arg, false)
+	case "alpha":
+		for _, color := range []*mgl32.Vec4{&f.Color, &f.KaraokeColor, &f.OutlineColor, &f.ShadowColor} {
+			setAlpha(color, arg)
+		}
+	case "1a":
+		setAlpha(&f.Color, arg)
+	case "2a":
+		setAlpha(&f.KaraokeColor, arg)
+	case "3a":
+		setAlpha(&f.OutlineColor, arg)
+	case "4a":
+		setAlpha(&f.ShadowColor, arg)
+	case "bord":
+		f.Outline = parseFloat(arg)
+	case "shad":
+		f.Shadow = parseFloat(arg)
+	case "an", "a":
+		// the first alignment tag of the line wins
+		if value, err := strconv.Atoi(arg); err == nil && cue.Alignment == 0 {
+			cue.Alignment = p.alignment(value, name == "a")
+		}
+	case "pos":
+		if args := tagArgs(arg); len(args) == 2 && cue.Position == nil && cue.Move == nil {
+			cue.Position = &mgl32.Vec2{parseFloat(args[0]), parseFloat(args[1])}
+		}
+	case "move":
+		args := tagArgs(arg)
+		if (len(args) != 4 && len(args) != 6) || cue.Position != nil || cue.Move != nil {
+			break
+		}
+		move := &Move{
+			From: mgl32.Vec2{parseFloat(args[0]), parseFloat(args[1])},
+			To:   mgl32.Vec2{parseFloat(args[2]), parseFloat(args[3])},
+		}
+		if len(args) == 6 {
+			move.Start = milliseconds(args[4])
+			move.End = milliseconds(args[5])
+		}
+		cue.Move = move
+	case "fad":
+		if args := tagArgs(arg); len(args) == 2 {
+			duration := cue.End - cue.Start
+			cue.Fade = &Fade{
+				Opacity: [3]float32{0, 1, 0},
+				Times:   [4]time.Duration{0, milliseconds(args[0]), duration - milliseconds(args[1]), duration},
+			}
+		}
+	case "fade":
+		if args := tagArgs(arg); len(args) == 7 {
+			fade := &Fade{}
+			for i := range fade.Opacity {
+				fade.Opacity[i] = 1 - parseFloat(args[i])/255
+			}
+			for i := range fade.Times {
+				fade.Times[i] = milliseconds(args[3+i])
+			}
+			cue.Fade = fade
+		}
+	case "k", "K", "kf", "ko":
+		// durations are in centiseconds
+		duration := time.Duration(parseFloat(arg)*10) * time.Millisecond
+		f.Karaoke = true
+		f.KaraokeStart = *karaoke
+		if name == "K" || name == "kf" {
+			f.KaraokeStart += duration / 2
+		}
+		*karaoke += duration
+	case "r":
+		reset := style
+		if arg != "" {
+			reset = p.style(arg)
+		}
+		state.format = reset.format
+		state.bold, state.italic = reset.bold, reset.italic
+	case "p":
+		scale, _ := strconv.Atoi(arg)
+		state.drawing = scale > 0
+	}
+}
+
+func milliseconds(value string) time.Duration {
+	return time.Duration(parseFloat(value)) * time.Millisecond
+}
+
+func parseFloat(value string) float32 {
+	number, err := strconv.ParseFloat(strings.TrimSpace(value), 32)
+	if err != nil {
+		return 0
+	}
+	return float32(number)
+}
+
+// Style flags are 0 or -1 (sometimes 1)
+func parseFlag(value string) bool {
+	number, err := strconv.Atoi(value)
+	return err == nil && number != 0
+}
+
+// Parses ASS colour &HAABBGGRR& (alpha is transparency) or decimal SSA colour.
+// Alpha is kept if the value doesn't have it and withAlpha is false (override tags)
+func setColor(color *mgl32.Vec4, value string, withAlpha bool) {
+	value = strings.TrimSpace(value)
+	var number uint64
+	var err error
+	hex := strings.Trim(strings.TrimPrefix(strings.TrimPrefix(value, "&H"), "&h"), "&")
+	if hex != value {
+		number, err = strconv.ParseUint(hex, 16, 32)
+	} else {
+		var signed int64
+		signed, err = strconv.ParseInt(value, 10, 64)
+		number = uint64(uint32(signed))
+	}
+	if err != nil || value == "" {
+		return
+	}
+	color[0] = float32(number&0xff) / 255
+	color[1] = float32(number>>8&0xff) / 255
+	color[2] = float32(number>>16&0xff) / 255
+	if withAlpha || len(hex) > 6 {
+		color[3] = 1 - float32(number>>24&0xff)/255
+	}
+}
+
+// Parses alpha &HAA& (0 - opaque, FF - transparent)
+func setAlpha(color *mgl32.Vec4, value string) {
+	hex := strings.Trim(strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(value), "&H"), "&h"), "&")
+	number, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return
+	}
+	color[3] = 1 - float32(number&0xff)/255
+}
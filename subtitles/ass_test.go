@@ -0,0 +1,117 @@
+package subtitles
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const assHeader = "[Script Info]\nScriptType: v4.00+\nPlayResX: 1280\nPlayResY: 720\n\n" +
+	"[V4+ Styles]\n" +
+	"Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, " +
+	"Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, " +
+	"Alignment, MarginL, MarginR, MarginV, Encoding\n" +
+	"Style: Default,Arial,40,&H00FFFFFF,&H000000FF,&H00000000,&H80000000,0,0,0,0,100,100,0,0,1,2,1,2,20,20,30,1\n\n" +
+	"[Events]\n"
+
+func TestParseASSOverrideTags(t *testing.T) {
+	input := assHeader +
+		"Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\n" +
+		"Dialogue: 1,0:00:01.00,0:00:03.50,Default,,0,0,0,,{\\an8\\pos(640,100)}Plain {\\b1\\c&H0000FF&}red bold{\\r}\\Nback\n"
+	track, err := ParseASS(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if track.Script == nil || track.Script.Width != 1280 || track.Script.Height != 720 {
+		t.Errorf("script %+v, want 1280x720", track.Script)
+	}
+	if len(track.Cues) != 1 {
+		t.Fatalf("%d cues, want 1", len(track.Cues))
+	}
+
+	cue := track.Cues[0]
+	if cue.Start != time.Second || cue.End != 3500*time.Millisecond {
+		t.Errorf("cue timing %v --> %v", cue.Start, cue.End)
+	}
+	if cue.Layer != 1 || cue.Alignment != 8 {
+		t.Errorf("layer %d, alignment %d, want 1 and 8", cue.Layer, cue.Alignment)
+	}
+	if cue.Position == nil || *cue.Position != (mgl32.Vec2{640, 100}) {
+		t.Errorf("position %v, want (640, 100)", cue.Position)
+	}
+	// zero margins of the event are taken from the style
+	if cue.Margins != [3]float32{20, 20, 30} {
+		t.Errorf("margins %v", cue.Margins)
+	}
+	if len(cue.Spans) != 3 {
+		t.Fatalf("spans %+v", cue.Spans)
+	}
+	plain, red, back := cue.Spans[0], cue.Spans[1], cue.Spans[2]
+	if plain.Text != "Plain " || plain.Bold || plain.Format.FontSize != 40 {
+		t.Errorf("plain span %+v", plain)
+	}
+	if red.Text != "red bold" || !red.Bold || red.Format.Color != (mgl32.Vec4{1, 0, 0, 1}) {
+		t.Errorf("red span %+v, colour %v", red, red.Format.Color)
+	}
+	if back.Text != "\nback" || back.Bold || back.Format.Color != (mgl32.Vec4{1, 1, 1, 1}) {
+		t.Errorf("reset span %+v, colour %v", back, back.Format.Color)
+	}
+}
+
+// Fields of the events follow their Format line, the text takes the rest of the line
+func TestParseASSFormatOrder(t *testing.T) {
+	input := assHeader +
+		"Format: Style, Layer, Start, End, Text\n" +
+		"Dialogue: Default,2,0:00:02.00,0:00:04.00,Hello, world\n"
+	track, err := ParseASS(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(track.Cues) != 1 {
+		t.Fatalf("%d cues, want 1", len(track.Cues))
+	}
+	cue := track.Cues[0]
+	if cue.Layer != 2 || cue.Start != 2*time.Second || cue.End != 4*time.Second {
+		t.Errorf("layer %d, timing %v --> %v", cue.Layer, cue.Start, cue.End)
+	}
+	if len(cue.Spans) != 1 || cue.Spans[0].Text != "Hello, world" {
+		t.Errorf("spans %+v", cue.Spans)
+	}
+}
+
+func TestParseASSUnterminatedOverride(t *testing.T) {
+	input := assHeader +
+		"Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\n" +
+		"Dialogue: 0,0:00:01.00,0:00:02.00,Default,,0,0,0,,Open {\\b1 brace\n"
+	track, err := ParseASS(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := track.Cues[0].Spans
+	if len(spans) != 1 || spans[0].Text != "Open {\\b1 brace" || spans[0].Bold {
+		t.Errorf("spans %+v, want the block kept as text", spans)
+	}
+}
+
+// Events with broken timing are skipped
+func TestParseASSBrokenTiming(t *testing.T) {
+	format := "Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\n"
+	input := assHeader + format +
+		"Dialogue: 0,0:00:xx.00,0:00:02.00,Default,,0,0,0,,broken\n" +
+		"Dialogue: 0,0:00:03.00,0:00:04.00,Default,,0,0,0,,good\n"
+	track, err := ParseASS(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(track.Cues) != 1 || track.Cues[0].Spans[0].Text != "good" {
+		t.Errorf("cues %+v, want the good one only", track.Cues)
+	}
+
+	input = assHeader + format + "Dialogue: 0,0:00:01.00,0:0x:02.00,Default,,0,0,0,,broken\n"
+	_, err = ParseASS(strings.NewReader(input))
+	if err == nil {
+		t.Error("file without valid events is parsed without error")
+	}
+}
@@ -0,0 +1,89 @@
+package subtitles
+
+// Text subtitle tracks stored in the video file (MKV, MP4)
+
+import (
+	"fmt"
+	"strings"
+	"videoplayer/container"
+)
+
+// Fields of ASS event in Matroska block, timing is stored by the block itself
+var matroskaEventFormat = []string{
+	"readorder", "layer", "style", "name", "marginl", "marginr", "marginv", "effect", "text",
+}
+
+// Reads text subtitle tracks of the video, tracks without cues are skipped
+func LoadEmbedded(videoPath string) ([]*Track, error) {
+	embedded, err := container.ReadSubtitles(videoPath)
+	if err != nil {
+		return nil, err
+	}
+	var tracks []*Track
+	for _, t := range embedded {
+		track := convertEmbedded(t)
+		if len(track.Cues) == 0 {
+			continue
+		}
+		sortCues(track.Cues)
+		track.Name = embeddedName(t)
+		track.Number = t.Number
+		track.Default = t.Default
+		tracks = append(tracks, track)
+	}
+	return tracks, nil
+}
+
+func convertEmbedded(t *container.SubtitleTrack) *Track {
+	if t.Codec == container.CodecASS {
+		return convertASS(t)
+	}
+	track := &Track{}
+	for _, sample := range t.Samples {
+		text := strings.TrimSpace(strings.ReplaceAll(string(sample.Data), "\r\n", "\n"))
+		if text == "" {
+			continue
+		}
+		cue := &Cue{Start: sample.Start, End: sample.End}
+		switch t.Codec {
+		case container.CodecSRT:
+			cue.Spans, cue.Alignment = parseMarkup(text)
+		case container.CodecWebVTT:
+			cue.Spans = parseVTTText(text)
+		default:
+			cue.Spans = []Span{{Text: text}}
+		}
+		track.Cues = append(track.Cues, cue)
+	}
+	return track
+}
+
+// Styles and resolution are read from the script header, events from the samples
+func convertASS(t *container.SubtitleTrack) *Track {
+	p := newASSParser()
+	header := strings.ReplaceAll(string(t.Header), "\r\n", "\n")
+	for _, line := range strings.Split(header, "\n") {
+		// header is not expected to have events, broken ones are skipped
+		_ = p.parseLine(strings.TrimSpace(line))
+	}
+	for _, sample := range t.Samples {
+		fields := splitFields(strings.TrimSpace(string(sample.Data)), matroskaEventFormat)
+		if cue := p.dialogueCue(fields, sample.Start, sample.End); cue != nil {
+			p.track.Cues = append(p.track.Cues, cue)
+		}
+	}
+	p.track.Script = p.resolution()
+	return p.track
+}
+
+// Name of the track like "#3 eng Signs"
+func embeddedName(t *container.SubtitleTrack) string {
+	name := fmt.Sprintf("#%d", t.Number)
+	if t.Language != "" {
+		name += " " + t.Language
+	}
+	if t.Title != "" {
+		name += " " + t.Title
+	}
+	return name
+}
@@ -0,0 +1,279 @@
+package subtitles
+
+// Draws cues over the video picture, text size follows the picture size.
+// ASS scripts are laid out in the script coordinates scaled to the whole picture,
+// other subtitles are placed into its visible part
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"videoplayer/text"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const (
+	minFontSize = 14    // logical pixels, subtitles stay readable in small windows
+	marginShare = 0.04  // distance from the picture edges relative to its height
+	outlineSize = 0.07  // outline width relative to the font size
+	shadowSize  = 0.05  // shadow offset relative to the font size
+	defaultSize = 0.055 // font size relative to the picture height
+	// ASS font size is the line height rather than the em size
+	assFontScale = 0.83
+)
+
+// Part of the window subtitles are placed in (usually the video picture), logical coordinates
+type Area struct {
+	X, Y          float32
+	Width, Height float32
+}
+
+type Style struct {
+	Size         float32 // font size relative to the area height
+	Color        mgl32.Vec4
+	OutlineColor mgl32.Vec4
+	ShadowColor  mgl32.Vec4
+	Alignment    int // used by cues without alignment, see Cue
+}
+
+// White text with black outline at the bottom of the picture
+func DefaultStyle() Style {
+	return Style{
+		Size:         defaultSize,
+		Color:        mgl32.Vec4{1, 1, 1, 1},
+		OutlineColor: mgl32.Vec4{0, 0, 0, 1},
+		ShadowColor:  mgl32.Vec4{0, 0, 0, 0.5},
+		Alignment:    2,
+	}
+}
+
+// Light yellow text at the top of the picture, used for the second track of dual subtitles
+func SecondaryStyle() Style {
+	style := DefaultStyle()
+	style.Color = mgl32.Vec4{1, 1, 0.6, 1}
+	style.Alignment = 8
+	return style
+}
+
+// Alignments of the position option of ParseStyle
+var positions = map[string]int{"bottom": 2, "middle": 5, "top": 8}
+
+// Changes the style by comma separated options: "size=5,color=#ffff00,position=top",
+// size is the percentage of the picture height, position is top, middle or bottom.
+// ASS tracks keep the styles of the script
+func ParseStyle(options string, style Style) (Style, error) {
+	for _, option := range strings.Split(options, ",") {
+		if strings.TrimSpace(option) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(option, "=")
+		if !ok {
+			return style, fmt.Errorf("invalid subtitle style option: %q", option)
+		}
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		switch name {
+		case "size":
+			size, err := strconv.ParseFloat(value, 32)
+			if err != nil || size <= 0 {
+				return style, fmt.Errorf("invalid subtitle size: %q", value)
+			}
+			style.Size = float32(size / 100)
+		case "color":
+			color, err := parseHexColor(value)
+			if err != nil {
+				return style, err
+			}
+			style.Color = *color
+		case "position":
+			alignment, ok := positions[value]
+			if !ok {
+				return style, fmt.Errorf("invalid subtitle position: %q", value)
+			}
+			style.Alignment = alignment
+		default:
+			return style, fmt.Errorf("unknown subtitle style option: %q", name)
+		}
+	}
+	return style, nil
+}
+
+type Renderer struct {
+	text *text.Renderer
+}
+
+func NewRenderer(textRenderer *text.Renderer) *Renderer {
+	return &Renderer{text: textRenderer}
+}
+
+// Draws cues of the track shown at the time.
+// picture is the whole video picture, visible is its part which is not covered by the controls
+func (r *Renderer) Draw(track *Track, at time.Duration, picture, visible Area, style Style) {
+	cues := track.Active(at)
+	if len(cues) == 0 {
+		return
+	}
+	if track.Script != nil {
+		r.drawScript(cues, at, track.Script, picture)
+		return
+	}
+	r.drawCues(cues, visible, style)
+}
+
+// Draws the cues inside of the area, cues with the same alignment are stacked
+func (r *Renderer) drawCues(cues []*Cue, area Area, style Style) {
+	if area.Width <= 0 || area.Height <= 0 {
+		return
+	}
+	base := r.textStyle(area, style)
+	var groups [10][]text.Span // by alignment
+	for _, cue := range cues {
+		alignment := cue.Alignment
+		if alignment == 0 {
+			alignment = style.Alignment
+		}
+		if len(groups[alignment]) > 0 {
+			groups[alignment] = append(groups[alignment], text.Span{Text: "\n", Style: base})
+		}
+		for _, span := range cue.Spans {
+			spanStyle := base
+			spanStyle.Bold = span.Bold
+			spanStyle.Italic = span.Italic
+			if span.Color != nil {
+				spanStyle.Color = *span.Color
+				spanStyle.Color[3] = style.Color.W()
+			}
+			groups[alignment] = append(groups[alignment], text.Span{Text: span.Text, Style: spanStyle})
+		}
+	}
+
+	margin := area.Height * marginShare
+	for alignment, spans := range groups {
+		if len(spans) == 0 {
+			continue
+		}
+		r.drawAligned(spans, alignment, area, [3]float32{margin, margin, margin})
+	}
+}
+
+// Draws the text inside of the area at the side set by alignment,
+// margins are left, right and vertical ones
+func (r *Renderer) drawAligned(spans []text.Span, alignment int, area Area, margins [3]float32) {
+	_, height := r.text.MeasureSpans(spans)
+	x, align := area.X+(area.Width+margins[0]-margins[1])/2, text.AlignCenter
+	switch (alignment - 1) % 3 {
+	case 0:
+		x, align = area.X+margins[0], text.AlignLeft
+	case 2:
+		x, align = area.X+area.Width-margins[1], text.AlignRight
+	}
+	y := area.Y + area.Height - margins[2] - height
+	switch (alignment - 1) / 3 {
+	case 1:
+		y = area.Y + (area.Height-height)/2
+	case 2:
+		y = area.Y + margins[2]
+	}
+	r.text.DrawSpans(spans, x, y, align)
+}
+
+// Draws ASS cues, positions and sizes are scaled from the script resolution to the picture
+func (r *Renderer) drawScript(cues []*Cue, at time.Duration, script *Script, picture Area) {
+	if picture.Width <= 0 || picture.Height <= 0 {
+		return
+	}
+	scaleX, scaleY := picture.Width/script.Width, picture.Height/script.Height
+	sort.SliceStable(cues, func(i, j int) bool {
+		return cues[i].Layer < cues[j].Layer
+	})
+
+	// cues without position are stacked like the other subtitles
+	var groups [10][]text.Span
+	var margins [10][3]float32
+	for _, cue := range cues {
+		spans := r.scriptSpans(cue, at, scaleY)
+		if len(spans) == 0 {
+			continue
+		}
+		position := cue.Position
+		if cue.Move != nil {
+			moved := cue.Move.Position(at-cue.Start, cue.End-cue.Start)
+			position = &moved
+		}
+		if position == nil {
+			if len(groups[cue.Alignment]) > 0 {
+				newLine := spans[0]
+				newLine.Text = "\n"
+				groups[cue.Alignment] = append(groups[cue.Alignment], newLine)
+			} else {
+				margins[cue.Alignment] = [3]float32{
+					cue.Margins[0] * scaleX, cue.Margins[1] * scaleX, cue.Margins[2] * scaleY,
+				}
+			}
+			groups[cue.Alignment] = append(groups[cue.Alignment], spans...)
+			continue
+		}
+
+		// alignment sets the point of the text box placed at the position
+		width, height := r.text.MeasureSpans(spans)
+		x := picture.X + position.X()*scaleX
+		y := picture.Y + position.Y()*scaleY
+		anchor := Area{X: x - width, Y: y - height, Width: 2 * width, Height: 2 * height}
+		r.drawAligned(spans, cue.Alignment, anchor, [3]float32{width, width, height})
+	}
+	for alignment, spans := range groups {
+		if len(spans) > 0 {
+			r.drawAligned(spans, alignment, picture, margins[alignment])
+		}
+	}
+}
+
+// Text spans of ASS cue at the time (with karaoke and fade applied)
+func (r *Renderer) scriptSpans(cue *Cue, at time.Duration, scale float32) []text.Span {
+	opacity := float32(1)
+	if cue.Fade != nil {
+		opacity = cue.Fade.Value(at - cue.Start)
+	}
+	var spans []text.Span
+	for _, span := range cue.Spans {
+		f := span.Format
+		color := f.Color
+		if f.Karaoke && at-cue.Start < f.KaraokeStart {
+			color = f.KaraokeColor
+		}
+		size := f.FontSize * assFontScale * scale
+		style := text.Style{
+			Size:         size,
+			Color:        color,
+			Outline:      f.Outline * scale,
+			OutlineColor: f.OutlineColor,
+			Shadow:       mgl32.Vec2{f.Shadow * scale, f.Shadow * scale},
+			ShadowColor:  f.ShadowColor,
+			Bold:         span.Bold,
+			Italic:       span.Italic,
+		}
+		style.Color[3] *= opacity
+		style.OutlineColor[3] *= opacity
+		style.ShadowColor[3] *= opacity
+		spans = append(spans, text.Span{Text: span.Text, Style: style})
+	}
+	return spans
+}
+
+// Text style for the area size
+func (r *Renderer) textStyle(area Area, style Style) text.Style {
+	size := area.Height * style.Size
+	if size < minFontSize {
+		size = minFontSize
+	}
+	return text.Style{
+		Size:         size,
+		Color:        style.Color,
+		Outline:      size * outlineSize,
+		OutlineColor: style.OutlineColor,
+		Shadow:       mgl32.Vec2{size * shadowSize, size * shadowSize},
+		ShadowColor:  style.ShadowColor,
+	}
+}
@@ -0,0 +1,253 @@
+package subtitles
+
+// SubRip (.srt) subtitles:
+//
+//	1
+//	00:00:01,000 --> 00:00:04,500
+//	Text with <i>italic</i>, <b>bold</b> and <font color="#ffff00">coloured</font> parts
+//
+// {\anN} position tags (from ASS) are supported as well
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func ParseSRT(r io.Reader) (*Track, error) {
+	text, err := readText(r)
+	if err != nil {
+		return nil, err
+	}
+	track := &Track{}
+	var blockErr error // error of the first broken block
+	lines := strings.Split(text, "\n")
+	for i := 0; i < len(lines); i++ {
+		// blocks are found by their timing lines, numbers are not needed
+		if !strings.Contains(lines[i], "-->") {
+			continue
+		}
+		start, end, err := parseTimeRange(lines[i])
+		var textLines []string
+		for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+			i++
+			textLines = append(textLines, strings.TrimSpace(lines[i]))
+		}
+		// broken block is skipped with its text
+		if err != nil {
+			if blockErr == nil {
+				blockErr = fmt.Errorf("line %d: %w", i+1-len(textLines), err)
+			}
+			continue
+		}
+		cue := &Cue{Start: start, End: end}
+		cue.Spans, cue.Alignment = parseMarkup(strings.Join(textLines, "\n"))
+		track.Cues = append(track.Cues, cue)
+	}
+	if len(track.Cues) == 0 {
+		if blockErr != nil {
+			return nil, blockErr
+		}
+		return nil, fmt.Errorf("no subtitles found")
+	}
+	sortCues(track.Cues)
+	return track, nil
+}
+
+// Parses "00:00:01,000 --> 00:00:04,500"
+func parseTimeRange(line string) (time.Duration, time.Duration, error) {
+	parts := strings.SplitN(line, "-->", 2)
+	start, err := parseTimestamp(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	// text after the end time (e.g. SRT coordinates or WebVTT cue settings) is ignored
+	fields := strings.Fields(parts[1])
+	if len(fields) == 0 {
+		return 0, 0, fmt.Errorf("end time is missing")
+	}
+	end, err := parseTimestamp(fields[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+// Parses [hh:]mm:ss[,.]mmm
+func parseTimestamp(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp: %q", s)
+	}
+	var t time.Duration
+	for _, part := range parts[:len(parts)-1] {
+		value, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, fmt.Errorf("invalid timestamp: %q", s)
+		}
+		t = t*60 + time.Duration(value)
+	}
+	seconds, err := strconv.ParseFloat(strings.Replace(parts[len(parts)-1], ",", ".", 1), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timestamp: %q", s)
+	}
+	return t*time.Minute + time.Duration(seconds*float64(time.Second)+0.5), nil
+}
+
+// Splits text with HTML-like tags into spans, returns alignment set by {\anN} tag.
+// Unknown tags are dropped
+func parseMarkup(text string) ([]Span, int) {
+	var spans []Span
+	var bold, italic int
+	var colors []*mgl32.Vec4
+	alignment := 0
+	current := strings.Builder{}
+
+	flush := func() {
+		if current.Len() == 0 {
+			return
+		}
+		span := Span{Text: current.String(), Bold: bold > 0, Italic: italic > 0}
+		if len(colors) > 0 {
+			span.Color = colors[len(colors)-1]
+		}
+		spans = append(spans, span)
+		current.Reset()
+	}
+
+	for len(text) > 0 {
+		switch {
+		case text[0] == '<':
+			end := strings.IndexByte(text, '>')
+			if end < 0 {
+				current.WriteString(text)
+				text = ""
+				continue
+			}
+			tag := strings.ToLower(strings.TrimSpace(text[1:end]))
+			text = text[end+1:]
+			flush()
+			name := tag
+			if i := strings.IndexAny(tag, " \t.="); i >= 0 {
+				name = tag[:i]
+			}
+			switch name {
+			case "b":
+				bold++
+			case "/b":
+				bold = decrement(bold)
+			case "i":
+				italic++
+			case "/i":
+				italic = decrement(italic)
+			case "font":
+				color, _ := parseFontColor(tag)
+				colors = append(colors, color)
+			case "c": // WebVTT class span, <c.yellow>
+				colors = append(colors, classColor(tag))
+			case "/font", "/c":
+				if len(colors) > 0 {
+					colors = colors[:len(colors)-1]
+				}
+			}
+		case text[0] == '{' && strings.HasPrefix(text, `{\`):
+			end := strings.IndexByte(text, '}')
+			if end < 0 {
+				current.WriteString(text)
+				text = ""
+				continue
+			}
+			for _, override := range strings.Split(text[2:end], `\`) {
+				if strings.HasPrefix(override, "an") {
+					if value, err := strconv.Atoi(override[2:]); err == nil && value >= 1 && value <= 9 {
+						alignment = value
+					}
+				}
+			}
+			text = text[end+1:]
+		default:
+			size := 1
+			if i := strings.IndexAny(text[1:], "<{"); i >= 0 {
+				size += i
+			} else {
+				size = len(text)
+			}
+			current.WriteString(text[:size])
+			text = text[size:]
+		}
+	}
+	flush()
+	return spans, alignment
+}
+
+func decrement(count int) int {
+	if count > 0 {
+		return count - 1
+	}
+	return 0
+}
+
+var colorNames = map[string]string{
+	"white":   "ffffff",
+	"black":   "000000",
+	"red":     "ff0000",
+	"green":   "008000",
+	"lime":    "00ff00",
+	"blue":    "0000ff",
+	"yellow":  "ffff00",
+	"cyan":    "00ffff",
+	"aqua":    "00ffff",
+	"magenta": "ff00ff",
+	"fuchsia": "ff00ff",
+	"orange":  "ffa500",
+	"gray":    "808080",
+	"grey":    "808080",
+	"silver":  "c0c0c0",
+}
+
+// Colour of <font color="#rrggbb"> tag, nil if it's not set
+func parseFontColor(tag string) (*mgl32.Vec4, error) {
+	i := strings.Index(tag, "color")
+	if i < 0 {
+		return nil, nil
+	}
+	fields := strings.Fields(strings.TrimLeft(tag[i+len("color"):], " ="))
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return parseHexColor(strings.Trim(fields[0], `"'`))
+}
+
+// Colour of the first colour class of <c.class1.class2> tag, nil if there is no such class
+func classColor(tag string) *mgl32.Vec4 {
+	for _, class := range strings.Split(tag, ".")[1:] {
+		if _, ok := colorNames[class]; ok {
+			color, _ := parseHexColor(class)
+			return color
+		}
+	}
+	return nil
+}
+
+// Parses "#rrggbb", "rrggbb" or colour name
+func parseHexColor(value string) (*mgl32.Vec4, error) {
+	value = strings.TrimPrefix(strings.ToLower(value), "#")
+	if name, ok := colorNames[value]; ok {
+		value = name
+	}
+	rgb, err := strconv.ParseUint(value, 16, 32)
+	if err != nil || len(value) != 6 {
+		return nil, fmt.Errorf("invalid colour: %q", value)
+	}
+	return &mgl32.Vec4{
+		float32(rgb>>16&0xff) / 255,
+		float32(rgb>>8&0xff) / 255,
+		float32(rgb&0xff) / 255,
+		1,
+	}, nil
+}
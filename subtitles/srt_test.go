@@ -0,0 +1,97 @@
+package subtitles
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func TestParseSRT(t *testing.T) {
+	input := "1\n00:00:01,000 --> 00:00:04,500\n<i>Italic</i> and <font color=\"#ffff00\">yellow</font>\n\n" +
+		"2\n00:00:05,250 --> 00:00:06,000 X1:10 X2:20 Y1:30 Y2:40\n{\\an8}Two\nlines\n\n"
+	track, err := ParseSRT(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(track.Cues) != 2 {
+		t.Fatalf("%d cues, want 2", len(track.Cues))
+	}
+
+	first := track.Cues[0]
+	if first.Start != time.Second || first.End != 4500*time.Millisecond {
+		t.Errorf("first cue timing %v --> %v", first.Start, first.End)
+	}
+	if len(first.Spans) != 3 {
+		t.Fatalf("first cue spans %+v", first.Spans)
+	}
+	if !first.Spans[0].Italic || first.Spans[0].Text != "Italic" {
+		t.Errorf("italic span %+v", first.Spans[0])
+	}
+	yellow := mgl32.Vec4{1, 1, 0, 1}
+	if color := first.Spans[2].Color; color == nil || *color != yellow || first.Spans[2].Text != "yellow" {
+		t.Errorf("coloured span %+v", first.Spans[2])
+	}
+
+	second := track.Cues[1]
+	if second.Start != 5250*time.Millisecond || second.End != 6*time.Second {
+		t.Errorf("second cue timing %v --> %v", second.Start, second.End)
+	}
+	if second.Alignment != 8 {
+		t.Errorf("second cue alignment %d, want 8", second.Alignment)
+	}
+	if second.Spans[0].Text != "Two\nlines" {
+		t.Errorf("second cue text %q", second.Spans[0].Text)
+	}
+}
+
+// Blocks with broken timing are skipped with their text
+func TestParseSRTBrokenTiming(t *testing.T) {
+	input := "1\n00:00:01,000 --> 00:00:xx,000\nbroken\n\n" +
+		"2\n00:00:02,000 -->\nno end\n\n" +
+		"3\n00:00:03,000 --> 00:00:04,000\ngood\n"
+	track, err := ParseSRT(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(track.Cues) != 1 || track.Cues[0].Spans[0].Text != "good" {
+		t.Errorf("cues %+v, want the good one only", track.Cues)
+	}
+
+	_, err = ParseSRT(strings.NewReader("1\n00:00:01,000 --> 00:00:xx,000\nbroken\n"))
+	if err == nil {
+		t.Error("file without valid blocks is parsed without error")
+	}
+}
+
+func TestParseSRTLineEndings(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"crlf with bom", "\xef\xbb\xbf1\r\n00:00:01,000 --> 00:00:02,000\r\nfirst\r\n\r\n" +
+			"2\r\n00:00:03,000 --> 00:00:04,000\r\nsecond\r\n\r\n"},
+		{"no trailing blank line", "1\n00:00:01,000 --> 00:00:02,000\nfirst\n\n" +
+			"2\n00:00:03,000 --> 00:00:04,000\nsecond"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			track, err := ParseSRT(strings.NewReader(test.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(track.Cues) != 2 {
+				t.Fatalf("%d cues, want 2", len(track.Cues))
+			}
+			for i, want := range []string{"first", "second"} {
+				if text := track.Cues[i].Spans[0].Text; text != want {
+					t.Errorf("cue %d text %q, want %q", i, text, want)
+				}
+			}
+			if track.Cues[1].End != 4*time.Second {
+				t.Errorf("last cue ends at %v", track.Cues[1].End)
+			}
+		})
+	}
+}
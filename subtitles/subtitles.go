@@ -0,0 +1,199 @@
+package subtitles
+
+// Text subtitles: parsed cues of a track and lookup of the cues shown at the playback time
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Extensions of the supported subtitle files, in order of preference for sidecar files
+var extensions = []string{".ass", ".ssa", ".srt", ".vtt"}
+
+// Part of the cue text with its own formatting
+type Span struct {
+	Text   string
+	Bold   bool
+	Italic bool
+	Color  *mgl32.Vec4 // nil - colour of the track style
+	Format *Format     // complete formatting of ASS text, nil for the other formats
+}
+
+// Formatting set by ASS styles and override tags, sizes are in script pixels
+type Format struct {
+	FontSize     float32
+	Color        mgl32.Vec4 // primary colour
+	KaraokeColor mgl32.Vec4 // secondary colour, shown until the karaoke syllable starts
+	OutlineColor mgl32.Vec4
+	ShadowColor  mgl32.Vec4
+	Outline      float32
+	Shadow       float32
+	Karaoke      bool          // span is a karaoke syllable
+	KaraokeStart time.Duration // relative to the cue start
+}
+
+type Cue struct {
+	Start time.Duration
+	End   time.Duration
+	// position on the screen like on a numeric keypad:
+	// 1-3 - bottom, 4-6 - middle, 7-9 - top (left, center, right), 0 - default (bottom center)
+	Alignment int
+	Spans     []Span
+	// used by ASS only
+	Layer    int        // cues of the higher layers are drawn over the lower ones
+	Margins  [3]float32 // left, right and vertical margins in script pixels
+	Position *mgl32.Vec2
+	Move     *Move
+	Fade     *Fade
+}
+
+// Movement of the cue (ASS \move), positions are in script pixels
+type Move struct {
+	From, To   mgl32.Vec2
+	Start, End time.Duration // relative to the cue start, zero end - the cue end
+}
+
+// Opacity change of the cue (ASS \fade)
+type Fade struct {
+	Opacity [3]float32       // before Times[0], between Times[1] and Times[2], after Times[3]
+	Times   [4]time.Duration // relative to the cue start
+}
+
+// Script resolution ASS positions and sizes are relative to
+type Script struct {
+	Width, Height float32
+}
+
+type Track struct {
+	Name    string
+	Path    string  // subtitle file, empty for embedded tracks
+	Number  int     // number of the embedded track in the container
+	Cues    []*Cue  // sorted by start time
+	Script  *Script // ASS only
+	Default bool    // embedded track is marked to be shown by default
+}
+
+// Cues shown at the time t
+func (t *Track) Active(at time.Duration) []*Cue {
+	var cues []*Cue
+	for _, cue := range t.Cues {
+		if cue.Start > at {
+			break
+		}
+		if cue.End > at {
+			cues = append(cues, cue)
+		}
+	}
+	return cues
+}
+
+// Loads subtitle file, format is detected by the extension
+func Load(path string) (*Track, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var track *Track
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".srt":
+		track, err = ParseSRT(f)
+	case ".ass", ".ssa":
+		track, err = ParseASS(f)
+	case ".vtt":
+		track, err = ParseVTT(f)
+	default:
+		err = fmt.Errorf("unsupported subtitle format")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	track.Name = filepath.Base(path)
+	track.Path = path
+	return track, nil
+}
+
+// Position of the moving cue at the time relative to its start
+func (m *Move) Position(t, duration time.Duration) mgl32.Vec2 {
+	end := m.End
+	if end <= m.Start {
+		end = duration
+	}
+	progress := float32(0)
+	if end > m.Start {
+		progress = float32(t-m.Start) / float32(end-m.Start)
+	}
+	progress = mgl32.Clamp(progress, 0, 1)
+	return m.From.Add(m.To.Sub(m.From).Mul(progress))
+}
+
+// Opacity of the cue at the time relative to its start
+func (f *Fade) Value(t time.Duration) float32 {
+	interpolate := func(from, to float32, start, end time.Duration) float32 {
+		if end <= start {
+			return to
+		}
+		return from + (to-from)*float32(t-start)/float32(end-start)
+	}
+	switch {
+	case t < f.Times[0]:
+		return f.Opacity[0]
+	case t < f.Times[1]:
+		return interpolate(f.Opacity[0], f.Opacity[1], f.Times[0], f.Times[1])
+	case t < f.Times[2]:
+		return f.Opacity[1]
+	case t < f.Times[3]:
+		return interpolate(f.Opacity[1], f.Opacity[2], f.Times[2], f.Times[3])
+	}
+	return f.Opacity[2]
+}
+
+// Returns path of the subtitle file with the same name as the video (e.g. movie.ass or movie.srt for movie.mp4),
+// empty string if there is no such file
+func FindSidecar(videoPath string) string {
+	base := strings.TrimSuffix(videoPath, filepath.Ext(videoPath))
+	for _, ext := range extensions {
+		for _, path := range []string{base + ext, base + strings.ToUpper(ext)} {
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// Reads the whole subtitle file as UTF-8 text with '\n' line endings.
+// Files which are not valid UTF-8 are treated as Windows-1252 (common for old SRT files)
+func readText(r io.Reader) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		data, err = charmap.Windows1252.NewDecoder().Bytes(data)
+		if err != nil {
+			return "", err
+		}
+	}
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	return strings.ReplaceAll(text, "\r", "\n"), nil
+}
+
+// Sorts cues by start time keeping the order of simultaneous ones
+func sortCues(cues []*Cue) {
+	sort.SliceStable(cues, func(i, j int) bool {
+		return cues[i].Start < cues[j].Start
+	})
+}
@@ -0,0 +1,156 @@
+package subtitles
+
+// WebVTT (.vtt) subtitles:
+//
+//	WEBVTT
+//
+//	intro
+//	00:01.000 --> 00:04.500 line:10% align:start
+//	Text with <i>italic</i>, <b>bold</b> and <c.yellow>coloured</c> parts
+//
+// Cue settings are mapped to the alignment (one of 9 screen areas),
+// regions, styles (STYLE blocks) and vertical text are not supported
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+)
+
+func ParseVTT(r io.Reader) (*Track, error) {
+	text, err := readText(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(text, "WEBVTT") {
+		return nil, fmt.Errorf("WEBVTT header is missing")
+	}
+	track := &Track{}
+	var blockErr error // error of the first broken cue
+	lines := strings.Split(text, "\n")
+	for i := 0; i < len(lines); i++ {
+		if isVTTBlock(lines[i]) {
+			for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+				i++
+			}
+			continue
+		}
+		// cues are found by their timing lines, identifiers are not needed
+		if !strings.Contains(lines[i], "-->") {
+			continue
+		}
+		timing := lines[i]
+		start, end, err := parseTimeRange(timing)
+		var textLines []string
+		for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+			i++
+			textLines = append(textLines, strings.TrimSpace(lines[i]))
+		}
+		// broken cue is skipped with its text
+		if err != nil {
+			if blockErr == nil {
+				blockErr = fmt.Errorf("line %d: %w", i+1-len(textLines), err)
+			}
+			continue
+		}
+		settings := strings.Fields(strings.SplitN(timing, "-->", 2)[1])[1:]
+		cue := &Cue{Start: start, End: end, Alignment: vttAlignment(settings)}
+		cue.Spans = parseVTTText(strings.Join(textLines, "\n"))
+		track.Cues = append(track.Cues, cue)
+	}
+	if len(track.Cues) == 0 {
+		if blockErr != nil {
+			return nil, blockErr
+		}
+		return nil, fmt.Errorf("no subtitles found")
+	}
+	sortCues(track.Cues)
+	return track, nil
+}
+
+// Checks if the line starts a comment, style or region block
+func isVTTBlock(line string) bool {
+	for _, name := range []string{"NOTE", "STYLE", "REGION"} {
+		if line == name || strings.HasPrefix(line, name+" ") || strings.HasPrefix(line, name+"\t") {
+			return true
+		}
+	}
+	return false
+}
+
+// Spans of the cue text, tags are like in SRT plus <c.colour> classes,
+// character references (&amp; etc.) are decoded
+func parseVTTText(text string) []Span {
+	spans, _ := parseMarkup(text)
+	for i := range spans {
+		spans[i].Text = html.UnescapeString(spans[i].Text)
+	}
+	return spans
+}
+
+// Converts cue settings to the alignment, 0 if they don't change the default position.
+// line sets the vertical position, align (or position without align) the horizontal one
+func vttAlignment(settings []string) int {
+	row, column := 0, 1 // bottom center
+	changed := false
+	for _, setting := range settings {
+		name, value, ok := strings.Cut(setting, ":")
+		if !ok {
+			continue
+		}
+		value, _, _ = strings.Cut(value, ",") // line and position alignment
+		switch name {
+		case "line":
+			if percent, ok := parsePercent(value); ok {
+				row = 2 - int(percent/34)
+				changed = true
+			} else if number, err := strconv.Atoi(value); err == nil {
+				// non-negative numbers are lines from the top, negative ones from the bottom
+				if number >= 0 {
+					row = 2
+				}
+				changed = true
+			}
+		case "position":
+			if percent, ok := parsePercent(value); ok && !hasSetting(settings, "align") {
+				column = int(percent / 34)
+				changed = true
+			}
+		case "align":
+			switch value {
+			case "start", "left":
+				column = 0
+			case "end", "right":
+				column = 2
+			}
+			changed = true
+		}
+	}
+	if !changed {
+		return 0
+	}
+	return row*3 + column + 1
+}
+
+// Parses "50%" as 50, values are limited to [0, 100]
+func parsePercent(value string) (float64, bool) {
+	if !strings.HasSuffix(value, "%") {
+		return 0, false
+	}
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+	if err != nil || percent < 0 || percent > 100 {
+		return 0, false
+	}
+	return percent, true
+}
+
+func hasSetting(settings []string, name string) bool {
+	for _, setting := range settings {
+		if strings.HasPrefix(setting, name+":") {
+			return true
+		}
+	}
+	return false
+}
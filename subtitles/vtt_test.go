@@ -0,0 +1,66 @@
+package subtitles
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseVTT(t *testing.T) {
+	input := "WEBVTT\n\n" +
+		"NOTE comment\nwith two lines\n\n" +
+		"intro\n00:01.000 --> 00:04.500 line:0 align:start\n<i>First</i> cue\n\n" +
+		"01:00:02.000 --> 01:00:03.000\nSecond &amp; last\n"
+	track, err := ParseVTT(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(track.Cues) != 2 {
+		t.Fatalf("%d cues, want 2", len(track.Cues))
+	}
+
+	first := track.Cues[0]
+	if first.Start != time.Second || first.End != 4500*time.Millisecond {
+		t.Errorf("first cue timing %v --> %v", first.Start, first.End)
+	}
+	if first.Alignment != 7 {
+		t.Errorf("first cue alignment %d, want 7 (top left)", first.Alignment)
+	}
+	if len(first.Spans) != 2 || !first.Spans[0].Italic || first.Spans[0].Text != "First" {
+		t.Errorf("first cue spans %+v", first.Spans)
+	}
+
+	second := track.Cues[1]
+	if second.Start != time.Hour+2*time.Second || second.End != time.Hour+3*time.Second {
+		t.Errorf("second cue timing %v --> %v", second.Start, second.End)
+	}
+	if second.Spans[0].Text != "Second & last" {
+		t.Errorf("second cue text %q", second.Spans[0].Text)
+	}
+}
+
+// Cues with broken timing are skipped with their text
+func TestParseVTTBrokenTiming(t *testing.T) {
+	input := "WEBVTT\n\n" +
+		"00:01.000 --> 00:xx.000\nbroken\n\n" +
+		"00:02.000 --> 00:03.000\ngood\n"
+	track, err := ParseVTT(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(track.Cues) != 1 || track.Cues[0].Spans[0].Text != "good" {
+		t.Errorf("cues %+v, want the good one only", track.Cues)
+	}
+
+	_, err = ParseVTT(strings.NewReader("WEBVTT\n\n00:01.000 --> 00:xx.000\nbroken\n"))
+	if err == nil {
+		t.Error("file without valid cues is parsed without error")
+	}
+}
+
+func TestParseVTTHeader(t *testing.T) {
+	_, err := ParseVTT(strings.NewReader("00:01.000 --> 00:02.000\ntext\n"))
+	if err == nil {
+		t.Error("file without WEBVTT header is parsed without error")
+	}
+}
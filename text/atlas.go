@@ -0,0 +1,164 @@
+package text
+
+// Glyphs are rasterized on the first use and packed into rows of the single channel atlas texture.
+// When the atlas is full it's cleared and filled again by the glyphs of the drawn text
+
+import (
+	"image"
+	"image/draw"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	atlasSize = 1024
+	// empty pixels around glyphs, room for the outline and synthetic bold
+	glyphPadding = 6
+	// faces kept between atlas resets, animated font sizes (e.g. ASS \t(\fs)) create many of them
+	maxFaces = 32
+)
+
+type glyphKey struct {
+	variant int
+	size    int // pixels per em
+	r       rune
+}
+
+type faceKey struct {
+	variant int
+	size    int
+}
+
+type glyph struct {
+	bounds  image.Rectangle // padded mask relative to the pen position on the baseline, empty for spaces
+	pos     image.Point     // top left corner of the padded mask in the atlas
+	advance float32
+	index   sfnt.GlyphIndex
+}
+
+type atlas struct {
+	texture   uint32
+	glyphs    map[glyphKey]*glyph
+	faces     map[faceKey]font.Face
+	buffer    sfnt.Buffer
+	x, y      int // free space in the current row
+	rowHeight int
+}
+
+func newAtlas() *atlas {
+	a := &atlas{}
+	gl.GenTextures(1, &a.texture)
+	gl.BindTexture(gl.TEXTURE_2D, a.texture)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.R8, atlasSize, atlasSize, 0, gl.RED, gl.UNSIGNED_BYTE, nil)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	a.reset()
+	return a
+}
+
+// Removes all glyphs and faces, padding of the new glyphs relies on the cleared texture
+func (a *atlas) reset() {
+	a.glyphs = make(map[glyphKey]*glyph)
+	a.resetFaces()
+	a.x, a.y, a.rowHeight = 0, 0, 0
+	zeros := make([]byte, atlasSize*atlasSize)
+	gl.BindTexture(gl.TEXTURE_2D, a.texture)
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	gl.TexSubImage2D(gl.TEXTURE_2D, 0, 0, 0, atlasSize, atlasSize, gl.RED, gl.UNSIGNED_BYTE, gl.Ptr(zeros))
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 4)
+}
+
+func (a *atlas) resetFaces() {
+	for _, face := range a.faces {
+		face.Close()
+	}
+	a.faces = make(map[faceKey]font.Face)
+}
+
+func (a *atlas) face(f *Font, variant, size int) font.Face {
+	key := faceKey{variant, size}
+	if face, ok := a.faces[key]; ok {
+		return face
+	}
+	// faces are cheap to create again, glyphs stay in the atlas
+	if len(a.faces) >= maxFaces {
+		a.resetFaces()
+	}
+	face, err := opentype.NewFace(f.variants[variant], &opentype.FaceOptions{
+		Size:    float64(size),
+		DPI:     72, // size is in pixels then
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		panic(err)
+	}
+	a.faces[key] = face
+	return face
+}
+
+// Returns the glyph rasterizing it on the first use,
+// false if there is no room for it in the atlas
+func (a *atlas) glyph(f *Font, variant, size int, r rune) (*glyph, bool) {
+	key := glyphKey{variant, size, r}
+	if g, ok := a.glyphs[key]; ok {
+		return g, true
+	}
+
+	index, _ := f.variants[variant].GlyphIndex(&a.buffer, r)
+	face := a.face(f, variant, size)
+	dr, mask, maskp, advance, _ := face.Glyph(fixed.Point26_6{}, r)
+	g := &glyph{advance: float32(advance) / 64, index: index}
+	padded := dr.Inset(-glyphPadding)
+	// glyphs which don't fit into the atlas at all are not shown
+	if mask == nil || dr.Empty() || padded.Dx() > atlasSize || padded.Dy() > atlasSize {
+		a.glyphs[key] = g
+		return g, true
+	}
+
+	width, height := padded.Dx(), padded.Dy()
+	if a.x+width > atlasSize {
+		a.x, a.y, a.rowHeight = 0, a.y+a.rowHeight, 0
+	}
+	if a.y+height > atlasSize {
+		return nil, false
+	}
+	pixels := image.NewAlpha(image.Rect(0, 0, width, height))
+	inner := image.Rect(glyphPadding, glyphPadding, glyphPadding+dr.Dx(), glyphPadding+dr.Dy())
+	draw.Draw(pixels, inner, mask, maskp, draw.Src)
+
+	gl.BindTexture(gl.TEXTURE_2D, a.texture)
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	gl.TexSubImage2D(gl.TEXTURE_2D, 0, int32(a.x), int32(a.y), int32(width), int32(height),
+		gl.RED, gl.UNSIGNED_BYTE, gl.Ptr(pixels.Pix))
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 4)
+
+	g.bounds = padded
+	g.pos = image.Point{X: a.x, Y: a.y}
+	a.x += width
+	if height > a.rowHeight {
+		a.rowHeight = height
+	}
+	a.glyphs[key] = g
+	return g, true
+}
+
+// Kerning between two glyphs in pixels
+func (a *atlas) kern(f *Font, variant, size int, left, right *glyph) float32 {
+	kern, err := f.variants[variant].Kern(&a.buffer, left.index, right.index, fixed.I(size), font.HintingFull)
+	if err != nil {
+		return 0
+	}
+	return float32(kern) / 64
+}
+
+// Ascent and line height in pixels
+func (a *atlas) metrics(f *Font, variant, size int) (float32, float32) {
+	metrics := a.face(f, variant, size).Metrics()
+	return float32(metrics.Ascent) / 64, float32(metrics.Height) / 64
+}
@@ -0,0 +1,63 @@
+package text
+
+// Fonts of the on-screen text.
+// Built-in Go fonts have separate bold and italic variants,
+// font loaded from a file is used for all variants
+// (bold and italic are synthesized by the renderer then)
+
+import (
+	"os"
+
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+)
+
+type Font struct {
+	variants  [4]*opentype.Font // see variant
+	synthetic bool              // bold and italic are made from the regular variant
+}
+
+// Index of the font variant
+func variant(bold, italic bool) int {
+	index := 0
+	if bold {
+		index |= 1
+	}
+	if italic {
+		index |= 2
+	}
+	return index
+}
+
+// Built-in Go fonts
+func DefaultFont() *Font {
+	font := &Font{}
+	for i, data := range [][]byte{goregular.TTF, gobold.TTF, goitalic.TTF, gobolditalic.TTF} {
+		parsed, err := opentype.Parse(data)
+		if err != nil {
+			panic(err)
+		}
+		font.variants[i] = parsed
+	}
+	return font
+}
+
+// Loads TrueType/OpenType font file
+func LoadFont(path string) (*Font, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := opentype.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	font := &Font{synthetic: true}
+	for i := range font.variants {
+		font.variants[i] = parsed
+	}
+	return font, nil
+}
@@ -0,0 +1,320 @@
+package text
+
+// Draws text over the window.
+// Positions and sizes are in logical coordinates (see buttons/metrics.go):
+// (0, 0) = (left, top) of the window. Glyphs are rasterized at the physical
+// resolution of the window, so the text stays crisp on scaled (HiDPI) displays.
+// Outline and shadow are drawn by shaders/text.fs
+
+import (
+	"math"
+	"strings"
+	"videoplayer/shaders"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const (
+	italicSlant     = 0.2  // horizontal shift of synthetic italic per pixel of height
+	boldWeight      = 0.04 // thickening of synthetic bold relative to the font size
+	floatsPerVertex = 4    // position and atlas coordinates, both in pixels
+)
+
+type Style struct {
+	Size         float32 // font size in logical pixels
+	Color        mgl32.Vec4
+	Outline      float32 // outline width in logical pixels, 0 - no outline
+	OutlineColor mgl32.Vec4
+	Shadow       mgl32.Vec2 // shadow offset in logical pixels, zero - no shadow
+	ShadowColor  mgl32.Vec4
+	Bold         bool
+	Italic       bool
+}
+
+// White text with black outline and shadow, readable over any picture
+func DefaultStyle() Style {
+	return Style{
+		Size:         18,
+		Color:        mgl32.Vec4{1, 1, 1, 1},
+		Outline:      1.5,
+		OutlineColor: mgl32.Vec4{0, 0, 0, 1},
+		Shadow:       mgl32.Vec2{1.5, 1.5},
+		ShadowColor:  mgl32.Vec4{0, 0, 0, 0.6},
+	}
+}
+
+// Part of the text drawn with its own style
+type Span struct {
+	Text  string
+	Style Style
+}
+
+// Horizontal alignment of the lines relative to the x coordinate
+type Align int
+
+const (
+	AlignLeft Align = iota
+	AlignCenter
+	AlignRight
+)
+
+// Vertices of one span
+type run struct {
+	style    Style
+	first    int32
+	count    int32
+	embolden float32 // synthetic bold in pixels
+}
+
+type Renderer struct {
+	window   *glfw.Window
+	sh       uint32
+	vao      uint32
+	vbo      uint32
+	font     *Font
+	atlas    *atlas
+	vertices []float32
+	runs     []run
+}
+
+func NewRenderer(w *glfw.Window, sh uint32, font *Font) *Renderer {
+	r := &Renderer{
+		window: w,
+		sh:     sh,
+		font:   font,
+		atlas:  newAtlas(),
+	}
+	gl.GenVertexArrays(1, &r.vao)
+	gl.GenBuffers(1, &r.vbo)
+	gl.BindVertexArray(r.vao)
+	gl.BindBuffer(gl.ARRAY_BUFFER, r.vbo)
+	gl.EnableVertexAttribArray(0)
+	gl.VertexAttribPointer(0, 2, gl.FLOAT, false, floatsPerVertex*4, nil)
+	gl.EnableVertexAttribArray(1)
+	gl.VertexAttribPointerWithOffset(1, 2, gl.FLOAT, false, floatsPerVertex*4, uintptr(8))
+	gl.BindVertexArray(0)
+	return r
+}
+
+// Window size in logical coordinates
+func (r *Renderer) Size() (float32, float32) {
+	fbWidth, fbHeight := r.window.GetFramebufferSize()
+	scale := r.scale()
+	return float32(fbWidth) / scale, float32(fbHeight) / scale
+}
+
+func (r *Renderer) scale() float32 {
+	scale, _ := r.window.GetContentScale()
+	if scale <= 0 {
+		return 1
+	}
+	return scale
+}
+
+// Size of the text box
+func (r *Renderer) Measure(s string, style Style) (float32, float32) {
+	return r.MeasureSpans([]Span{{Text: s, Style: style}})
+}
+
+func (r *Renderer) MeasureSpans(spans []Span) (float32, float32) {
+	width, height, ok := r.layout(spans, 0, 0, AlignLeft, false)
+	if !ok {
+		r.atlas.reset()
+		width, height, _ = r.layout(spans, 0, 0, AlignLeft, false)
+	}
+	return width, height
+}
+
+// Draws the text with its top left corner at (x, y), '\n' starts a new line
+func (r *Renderer) Draw(s string, x, y float32, style Style) {
+	r.DrawSpans([]Span{{Text: s, Style: style}}, x, y, AlignLeft)
+}
+
+// Draws the spans one after another starting at the top of the text box y,
+// lines are aligned relative to x
+func (r *Renderer) DrawSpans(spans []Span, x, y float32, align Align) {
+	if _, _, ok := r.layout(spans, x, y, align, true); !ok {
+		// glyphs of the other texts are rasterized again on their next draw
+		r.atlas.reset()
+		r.layout(spans, x, y, align, true)
+	}
+	if len(r.vertices) == 0 {
+		return
+	}
+
+	fbWidth, fbHeight := r.window.GetFramebufferSize()
+	scale := r.scale()
+	resolution := mgl32.Vec2{float32(fbWidth), float32(fbHeight)}
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, r.vbo)
+	gl.BufferData(gl.ARRAY_BUFFER, 4*len(r.vertices), gl.Ptr(r.vertices), gl.STREAM_DRAW)
+	gl.Enable(gl.BLEND)
+	gl.BlendFuncSeparate(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA, gl.ONE, gl.ONE_MINUS_SRC_ALPHA)
+	defer gl.Disable(gl.BLEND)
+
+	shaders.Use(r.sh)
+	shaders.SetVec2(r.sh, "resolution", &resolution)
+	shaders.SetFloat(r.sh, "atlasSize", atlasSize)
+	shaders.SetInt(r.sh, "atlas", 0)
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, r.atlas.texture)
+	gl.BindVertexArray(r.vao)
+	defer gl.BindVertexArray(0)
+
+	// shadows go under all of the spans
+	shaders.SetBool(r.sh, "shadow", true)
+	for _, run := range r.runs {
+		if run.style.Shadow == (mgl32.Vec2{}) || run.style.ShadowColor.W() <= 0 {
+			continue
+		}
+		offset := run.style.Shadow.Mul(scale)
+		r.drawRun(run, offset, run.style.ShadowColor, scale)
+	}
+	shaders.SetBool(r.sh, "shadow", false)
+	for _, run := range r.runs {
+		r.drawRun(run, mgl32.Vec2{}, run.style.Color, scale)
+	}
+}
+
+func (r *Renderer) drawRun(run run, offset mgl32.Vec2, color mgl32.Vec4, scale float32) {
+	// outline is limited by the free space around glyphs in the atlas
+	outline := run.style.Outline * scale
+	if limit := glyphPadding - run.embolden - 0.5; outline > limit {
+		outline = limit
+	}
+	shaders.SetVec2(r.sh, "offset", &offset)
+	shaders.SetVec4(r.sh, "color", &color)
+	shaders.SetVec4(r.sh, "outlineColor", &run.style.OutlineColor)
+	shaders.SetFloat(r.sh, "outline", outline)
+	shaders.SetFloat(r.sh, "embolden", run.embolden)
+	gl.DrawArrays(gl.TRIANGLES, run.first, run.count)
+}
+
+// Lays out the spans in framebuffer pixels, glyph quads are stored in r.vertices if emit is set.
+// Returns size of the text box in logical coordinates,
+// false if some glyphs didn't fit into the atlas
+func (r *Renderer) layout(spans []Span, x, y float32, align Align, emit bool) (float32, float32, bool) {
+	scale := r.scale()
+	r.vertices = r.vertices[:0]
+	r.runs = r.runs[:0]
+	complete := true
+	var width float32
+	top := y * scale
+	for _, line := range splitLines(spans) {
+		var ascent, lineHeight float32
+		for _, span := range line {
+			lineAscent, height := r.atlas.metrics(r.font, r.variant(span.Style), r.pixelSize(span.Style, scale))
+			ascent = float32(math.Max(float64(ascent), float64(lineAscent)))
+			lineHeight = float32(math.Max(float64(lineHeight), float64(height)))
+		}
+		lineWidth := r.walk(line, scale, 0, 0, false, &complete)
+		width = float32(math.Max(float64(width), float64(lineWidth)))
+		if emit {
+			left := x * scale
+			switch align {
+			case AlignCenter:
+				left -= lineWidth / 2
+			case AlignRight:
+				left -= lineWidth
+			}
+			r.walk(line, scale, left, top+ascent, true, &complete)
+		}
+		top += lineHeight
+	}
+	return width / scale, top/scale - y, complete
+}
+
+// Advances the pen through the line starting at (left, baseline),
+// glyph quads are added if emit is set. Returns width of the line in pixels
+func (r *Renderer) walk(line []Span, scale, left, baseline float32, emit bool, complete *bool) float32 {
+	var pen float32
+	baseline = float32(math.Round(float64(baseline)))
+	for _, span := range line {
+		size := r.pixelSize(span.Style, scale)
+		variant := r.variant(span.Style)
+		var embolden float32
+		if r.font.synthetic && span.Style.Bold {
+			embolden = float32(math.Min(float64(size)*boldWeight, glyphPadding/2))
+		}
+		slant := float32(0)
+		if r.font.synthetic && span.Style.Italic {
+			slant = italicSlant
+		}
+
+		first := int32(len(r.vertices) / floatsPerVertex)
+		var previous *glyph
+		for _, char := range span.Text {
+			g, ok := r.atlas.glyph(r.font, variant, size, char)
+			if !ok {
+				*complete = false
+				previous = nil
+				continue
+			}
+			if previous != nil {
+				pen += r.atlas.kern(r.font, variant, size, previous, g)
+			}
+			if emit && !g.bounds.Empty() {
+				r.addQuad(g, float32(math.Round(float64(left+pen))), baseline, slant)
+			}
+			pen += g.advance + embolden
+			previous = g
+		}
+		if emit {
+			count := int32(len(r.vertices)/floatsPerVertex) - first
+			r.runs = append(r.runs, run{style: span.Style, first: first, count: count, embolden: embolden})
+		}
+	}
+	return pen
+}
+
+// Adds two triangles of the glyph placed at the pen position
+func (r *Renderer) addQuad(g *glyph, x, baseline, slant float32) {
+	x0, y0 := x+float32(g.bounds.Min.X), baseline+float32(g.bounds.Min.Y)
+	x1, y1 := x+float32(g.bounds.Max.X), baseline+float32(g.bounds.Max.Y)
+	u0, v0 := float32(g.pos.X), float32(g.pos.Y)
+	u1, v1 := u0+float32(g.bounds.Dx()), v0+float32(g.bounds.Dy())
+	// synthetic italic shifts the top of the glyph to the right
+	topShift, bottomShift := (baseline-y0)*slant, (baseline-y1)*slant
+	r.vertices = append(r.vertices,
+		x0+topShift, y0, u0, v0,
+		x1+topShift, y0, u1, v0,
+		x1+bottomShift, y1, u1, v1,
+
+		x0+topShift, y0, u0, v0,
+		x1+bottomShift, y1, u1, v1,
+		x0+bottomShift, y1, u0, v1,
+	)
+}
+
+// Font size in framebuffer pixels
+func (r *Renderer) pixelSize(style Style, scale float32) int {
+	size := int(math.Round(float64(style.Size * scale)))
+	if size < 1 {
+		return 1
+	}
+	return size
+}
+
+func (r *Renderer) variant(style Style) int {
+	if r.font.synthetic {
+		return variant(false, false)
+	}
+	return variant(style.Bold, style.Italic)
+}
+
+// Splits the spans by '\n', every line keeps at least one span for its height
+func splitLines(spans []Span) [][]Span {
+	lines := [][]Span{nil}
+	for _, span := range spans {
+		for i, part := range strings.Split(span.Text, "\n") {
+			if i > 0 {
+				lines = append(lines, nil)
+			}
+			last := len(lines) - 1
+			lines[last] = append(lines[last], Span{Text: part, Style: span.Style})
+		}
+	}
+	return lines
+}
@@ -0,0 +1,78 @@
+package textures
+
+import (
+	"time"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+const timerQueries = 4
+
+// Upload timings collected in benchmark mode
+type UploadStats struct {
+	Frames  int
+	CPUTime time.Duration // time spent by render thread
+	CPUMax  time.Duration
+	GPUTime time.Duration // time spent by GPU (including asynchronous transfer)
+	GPUMax  time.Duration
+	Skipped int // uploads of unchanged frames which were skipped
+}
+
+// Timings of the frame uploads, GPU time is measured with timer queries
+type uploadBench struct {
+	queries  [timerQueries]uint32
+	pending  [timerQueries]bool
+	next     int
+	cpuStart time.Time
+	stats    UploadStats
+}
+
+func newUploadBench() *uploadBench {
+	ub := &uploadBench{}
+	gl.GenQueries(timerQueries, &ub.queries[0])
+	return ub
+}
+
+func (ub *uploadBench) begin() {
+	ub.collect(ub.next)
+	gl.BeginQuery(gl.TIME_ELAPSED, ub.queries[ub.next])
+	ub.cpuStart = time.Now()
+}
+
+func (ub *uploadBench) end() {
+	cpuTime := time.Since(ub.cpuStart)
+	gl.EndQuery(gl.TIME_ELAPSED)
+	ub.pending[ub.next] = true
+	ub.next = (ub.next + 1) % timerQueries
+
+	ub.stats.Frames++
+	ub.stats.CPUTime += cpuTime
+	if cpuTime > ub.stats.CPUMax {
+		ub.stats.CPUMax = cpuTime
+	}
+}
+
+// Reads result of the query i (waits for it if it isn't ready yet)
+func (ub *uploadBench) collect(i int) {
+	if !ub.pending[i] {
+		return
+	}
+	var elapsed uint64
+	gl.GetQueryObjectui64v(ub.queries[i], gl.QUERY_RESULT, &elapsed)
+	ub.pending[i] = false
+	gpuTime := time.Duration(elapsed)
+	ub.stats.GPUTime += gpuTime
+	if gpuTime > ub.stats.GPUMax {
+		ub.stats.GPUMax = gpuTime
+	}
+}
+
+// Returns stats collected since the previous call
+func (ub *uploadBench) flush() UploadStats {
+	for i := range ub.queries {
+		ub.collect(i)
+	}
+	stats := ub.stats
+	ub.stats = UploadStats{}
+	return stats
+}
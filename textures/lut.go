@@ -0,0 +1,115 @@
+package textures
+
+import (
+	"videoplayer/loaders"
+	"videoplayer/shaders"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// texture units of the LUT samplers, units 0 and 5 are used by frame textures
+const (
+	lut3DUnit = 3
+	lut1DUnit = 4
+)
+
+// Colour lookup table uploaded to the GPU,
+// 3D tables are sampled with trilinear interpolation
+type LUT struct {
+	texture   uint32
+	is3D      bool
+	size      int32
+	domainMin mgl32.Vec3
+	domainMax mgl32.Vec3
+
+	// 1D shaper applied before the 3D table, shaper == 0 if there is none
+	shaper          uint32
+	shaperSize      int32
+	shaperDomainMin mgl32.Vec3
+	shaperDomainMax mgl32.Vec3
+}
+
+func NewLUT(cube *loaders.CubeLUT) *LUT {
+	lut := &LUT{
+		is3D:      cube.Is3D,
+		size:      int32(cube.Size),
+		domainMin: mgl32.Vec3(cube.DomainMin),
+		domainMax: mgl32.Vec3(cube.DomainMax),
+	}
+
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	if lut.is3D {
+		lut.texture = newLUTTexture(gl.TEXTURE_3D)
+		gl.TexImage3D(gl.TEXTURE_3D, 0, gl.RGB32F, lut.size, lut.size, lut.size, 0, gl.RGB, gl.FLOAT, gl.Ptr(cube.Data))
+		gl.BindTexture(gl.TEXTURE_3D, 0)
+	} else {
+		lut.texture = newLUTTexture(gl.TEXTURE_1D)
+		gl.TexImage1D(gl.TEXTURE_1D, 0, gl.RGB32F, lut.size, 0, gl.RGB, gl.FLOAT, gl.Ptr(cube.Data))
+		gl.BindTexture(gl.TEXTURE_1D, 0)
+	}
+	if shaper := cube.Shaper; shaper != nil {
+		lut.shaperSize = int32(shaper.Size)
+		lut.shaperDomainMin = mgl32.Vec3(shaper.DomainMin)
+		lut.shaperDomainMax = mgl32.Vec3(shaper.DomainMax)
+		lut.shaper = newLUTTexture(gl.TEXTURE_1D)
+		gl.TexImage1D(gl.TEXTURE_1D, 0, gl.RGB32F, lut.shaperSize, 0, gl.RGB, gl.FLOAT, gl.Ptr(shaper.Data))
+		gl.BindTexture(gl.TEXTURE_1D, 0)
+	}
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 4)
+	return lut
+}
+
+// Creates linearly filtered clamped texture and leaves it bound to target
+func newLUTTexture(target uint32) uint32 {
+	var texture uint32
+	gl.GenTextures(1, &texture)
+	gl.BindTexture(target, texture)
+	gl.TexParameteri(target, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(target, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(target, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(target, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(target, gl.TEXTURE_WRAP_R, gl.CLAMP_TO_EDGE)
+	return texture
+}
+
+// Binds table textures and sets LUT uniforms of the video shader
+func (lut *LUT) Bind(sh uint32) {
+	setLUTUnits(sh)
+	shaders.SetBool(sh, "lutEnabled", true)
+	shaders.SetBool(sh, "lut3D", lut.is3D)
+	shaders.SetFloat(sh, "lutSize", float32(lut.size))
+	shaders.SetVec3(sh, "lutDomainMin", &lut.domainMin)
+	shaders.SetVec3(sh, "lutDomainMax", &lut.domainMax)
+	shaders.SetBool(sh, "lutShaper", lut.shaper != 0)
+
+	if lut.is3D {
+		gl.ActiveTexture(gl.TEXTURE0 + lut3DUnit)
+		gl.BindTexture(gl.TEXTURE_3D, lut.texture)
+	} else {
+		gl.ActiveTexture(gl.TEXTURE0 + lut1DUnit)
+		gl.BindTexture(gl.TEXTURE_1D, lut.texture)
+	}
+	// only 3D tables have shapers, so 1D unit is free for it
+	if lut.shaper != 0 {
+		shaders.SetFloat(sh, "lutShaperSize", float32(lut.shaperSize))
+		shaders.SetVec3(sh, "lutShaperDomainMin", &lut.shaperDomainMin)
+		shaders.SetVec3(sh, "lutShaperDomainMax", &lut.shaperDomainMax)
+		gl.ActiveTexture(gl.TEXTURE0 + lut1DUnit)
+		gl.BindTexture(gl.TEXTURE_1D, lut.shaper)
+	}
+	gl.ActiveTexture(gl.TEXTURE0)
+}
+
+// Turns LUT off in the video shader
+func DisableLUT(sh uint32) {
+	setLUTUnits(sh)
+	shaders.SetBool(sh, "lutEnabled", false)
+}
+
+// Samplers of different types must not refer to the same texture unit
+// even if they aren't used
+func setLUTUnits(sh uint32) {
+	shaders.SetInt(sh, "lut3d", lut3DUnit)
+	shaders.SetInt(sh, "lut1d", lut1DUnit)
+}
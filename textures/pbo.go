@@ -0,0 +1,62 @@
+package textures
+
+import (
+	"unsafe"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// Ring of pixel buffer objects used to stream frames to textures.
+// Frame is copied into the next buffer and texture is updated from it
+// by the driver asynchronously, so the transfer overlaps rendering.
+// Buffer isn't reused until the rest of the ring was used
+type pixelBuffers struct {
+	ids  []uint32
+	next int
+}
+
+func newPixelBuffers(count int) *pixelBuffers {
+	ids := make([]uint32, count)
+	gl.GenBuffers(int32(count), &ids[0])
+	return &pixelBuffers{ids: ids}
+}
+
+// Copies planes into the next buffer one after another
+// and leaves the buffer bound as PIXEL_UNPACK_BUFFER.
+// Returns offsets of the planes in the buffer,
+// ok = false if the buffer couldn't be mapped (nothing is bound then)
+func (pb *pixelBuffers) load(planes ...[]uint8) (offsets []int, ok bool) {
+	size := 0
+	offsets = make([]int, len(planes))
+	for i, plane := range planes {
+		offsets[i] = size
+		size += len(plane)
+	}
+
+	id := pb.ids[pb.next]
+	pb.next = (pb.next + 1) % len(pb.ids)
+
+	gl.BindBuffer(gl.PIXEL_UNPACK_BUFFER, id)
+	// orphan previous storage, so mapping doesn't wait for the transfer still using it
+	gl.BufferData(gl.PIXEL_UNPACK_BUFFER, size, nil, gl.STREAM_DRAW)
+	ptr := gl.MapBufferRange(gl.PIXEL_UNPACK_BUFFER, 0, size,
+		gl.MAP_WRITE_BIT|gl.MAP_INVALIDATE_BUFFER_BIT)
+	if ptr == nil {
+		gl.BindBuffer(gl.PIXEL_UNPACK_BUFFER, 0)
+		return nil, false
+	}
+	mapped := unsafe.Slice((*uint8)(ptr), size)
+	for i, plane := range planes {
+		copy(mapped[offsets[i]:], plane)
+	}
+	if !gl.UnmapBuffer(gl.PIXEL_UNPACK_BUFFER) {
+		// buffer content got corrupted (e.g. on display mode change)
+		gl.BindBuffer(gl.PIXEL_UNPACK_BUFFER, 0)
+		return nil, false
+	}
+	return offsets, true
+}
+
+func (pb *pixelBuffers) unbind() {
+	gl.BindBuffer(gl.PIXEL_UNPACK_BUFFER, 0)
+}
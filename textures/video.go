@@ -0,0 +1,153 @@
+package textures
+
+// Texture holding the current RGBA video frame,
+// it's reallocated whenever frame size changes.
+// Previous frame can be kept in the second texture (for deinterlacing)
+
+import (
+	"image"
+	"videoplayer/multithread"
+	"videoplayer/shaders"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// Texture of one frame
+type frameTextures struct {
+	rgba     uint32
+	rgbaSize image.Point
+}
+
+// texture unit of the previous frame, see Bind
+const previousUnit = 5
+
+type VideoTexture struct {
+	frames       [2]frameTextures // current and previous frame
+	current      int              // index of the current frame in frames
+	keepPrevious bool
+	width        int32 // size of the last uploaded frame
+	height       int32
+	frame        *multithread.Frame // last uploaded frame
+	pbos         *pixelBuffers      // nil - synchronous uploads
+	bench        *uploadBench       // nil - benchmark mode is off
+	skipped      int
+}
+
+func NewVideoTexture(width, height int32) *VideoTexture {
+	vt := &VideoTexture{
+		width:  width,
+		height: height,
+	}
+	vt.frames[0].allocRGBA(image.Point{X: int(width), Y: int(height)})
+	return vt
+}
+
+// Keeps previous frame in separate texture, see Bind
+func (vt *VideoTexture) SetKeepPrevious(keep bool) {
+	vt.keepPrevious = keep
+}
+
+// Size of the last uploaded frame
+func (vt *VideoTexture) Size() (int32, int32) {
+	return vt.width, vt.height
+}
+
+func (ft *frameTextures) allocRGBA(size image.Point) {
+	if ft.rgba != 0 && ft.rgbaSize == size {
+		return
+	}
+	if ft.rgba == 0 {
+		gl.GenTextures(1, &ft.rgba)
+	}
+
+	gl.BindTexture(gl.TEXTURE_2D, ft.rgba)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA, int32(size.X), int32(size.Y), 0, gl.RGBA, gl.UNSIGNED_BYTE, nil)
+	// frame is sampled at its own resolution, scaling is done by scaling package
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	ft.rgbaSize = size
+}
+
+// Streams frames through count pixel buffer objects (2 - double, 3 - triple buffering),
+// 0 turns them off
+func (vt *VideoTexture) SetPixelBuffers(count int) {
+	if count <= 0 {
+		vt.pbos = nil
+		return
+	}
+	vt.pbos = newPixelBuffers(count)
+}
+
+// Starts measuring upload times, see UploadStats
+func (vt *VideoTexture) EnableBenchmark() {
+	vt.bench = newUploadBench()
+}
+
+// Returns upload timings collected since the previous call (benchmark mode only)
+func (vt *VideoTexture) UploadStats() UploadStats {
+	if vt.bench == nil {
+		return UploadStats{}
+	}
+	stats := vt.bench.flush()
+	stats.Skipped = vt.skipped
+	vt.skipped = 0
+	return stats
+}
+
+// Uploads frame into texture unless it's already there
+func (vt *VideoTexture) Upload(frame *multithread.Frame) {
+	if frame == vt.frame {
+		vt.skipped++
+		return
+	}
+	if vt.bench != nil {
+		vt.bench.begin()
+	}
+	if vt.keepPrevious {
+		vt.current = 1 - vt.current
+	}
+	vt.UploadRGBA(frame.Image)
+	if vt.bench != nil {
+		vt.bench.end()
+	}
+	vt.frame = frame
+}
+
+func (vt *VideoTexture) UploadRGBA(img *image.RGBA) {
+	ft := &vt.frames[vt.current]
+	size := img.Rect.Size()
+	// allocation has to be done before pixel buffer is bound
+	ft.allocRGBA(size)
+
+	gl.BindTexture(gl.TEXTURE_2D, ft.rgba)
+	pixels := gl.Ptr(img.Pix)
+	if vt.pbos != nil {
+		if offsets, ok := vt.pbos.load(img.Pix); ok {
+			pixels = gl.PtrOffset(offsets[0])
+			defer vt.pbos.unbind()
+		}
+	}
+	gl.PixelStorei(gl.UNPACK_ROW_LENGTH, int32(img.Stride/4))
+	gl.TexSubImage2D(gl.TEXTURE_2D, 0, 0, 0, int32(size.X), int32(size.Y), gl.RGBA, gl.UNSIGNED_BYTE, pixels)
+	gl.PixelStorei(gl.UNPACK_ROW_LENGTH, 0)
+	vt.width, vt.height = int32(size.X), int32(size.Y)
+}
+
+// Binds frame texture to texture unit 0 (previous frame to unit 5)
+func (vt *VideoTexture) Bind(sh uint32) {
+	shaders.SetInt(sh, "texImage", 0)
+	shaders.SetInt(sh, "texPrev", previousUnit)
+	vt.frames[vt.current].bind(0)
+	if vt.keepPrevious {
+		vt.frames[1-vt.current].bind(previousUnit)
+	}
+	gl.ActiveTexture(gl.TEXTURE0)
+}
+
+// Binds texture to the unit
+func (ft *frameTextures) bind(unit uint32) {
+	gl.ActiveTexture(gl.TEXTURE0 + unit)
+	gl.BindTexture(gl.TEXTURE_2D, ft.rgba)
+}
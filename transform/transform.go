@@ -0,0 +1,234 @@
+package transform
+
+// Placement of the video picture in the window
+// (aspect ratio, crop, rotation, flips, zoom and pan) expressed as the view matrix
+// of the full screen quad. Quad [-1, 1] square holds the whole frame,
+// its top edge (y = 1) is the top of the frame
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const (
+	minZoom = 0.1
+	maxZoom = 20.0
+)
+
+// aspect ratios cycled by NextAspect, 0 - the stream one
+var aspectPresets = []float64{0, 4.0 / 3, 16.0 / 9, 2.35}
+
+type View struct {
+	Aspect       float64         // display aspect ratio of the frame, 0 - by frame size and sample aspect ratio
+	SampleAspect float64         // pixel aspect ratio of the stream, 0 - square pixels
+	Crop         image.Rectangle // shown part of the frame in pixels, empty - whole frame
+	Zoom         float64         // 1 - picture fits the window
+	PanX, PanY   float64         // offset of the picture centre in normalized device coordinates
+	PixelPerfect bool            // one frame pixel per framebuffer pixel
+	Rotation     int             // clockwise rotation in degrees: 0, 90, 180 or 270
+	FlipH, FlipV bool            // mirroring of the rotated picture
+}
+
+func NewView() *View {
+	return &View{Zoom: 1}
+}
+
+// Parses "auto", "W:H" or decimal ratio (e.g. "2.35")
+func ParseAspect(s string) (float64, error) {
+	if s == "auto" || s == "" {
+		return 0, nil
+	}
+	if w, h, ok := strings.Cut(s, ":"); ok {
+		width, err1 := strconv.ParseFloat(w, 64)
+		height, err2 := strconv.ParseFloat(h, 64)
+		if err1 != nil || err2 != nil || width <= 0 || height <= 0 {
+			return 0, fmt.Errorf("invalid aspect ratio: %s", s)
+		}
+		return width / height, nil
+	}
+	ratio, err := strconv.ParseFloat(s, 64)
+	if err != nil || ratio <= 0 {
+		return 0, fmt.Errorf("invalid aspect ratio: %s", s)
+	}
+	return ratio, nil
+}
+
+// Parses crop rectangle in "W:H:X:Y" form (frame pixels)
+func ParseCrop(s string) (image.Rectangle, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 4 {
+		return image.Rectangle{}, fmt.Errorf("invalid crop rectangle: %s (W:H:X:Y expected)", s)
+	}
+	var values [4]int
+	for i, part := range parts {
+		value, err := strconv.Atoi(part)
+		if err != nil || value < 0 {
+			return image.Rectangle{}, fmt.Errorf("invalid crop rectangle: %s", s)
+		}
+		values[i] = value
+	}
+	if values[0] == 0 || values[1] == 0 {
+		return image.Rectangle{}, fmt.Errorf("empty crop rectangle: %s", s)
+	}
+	return image.Rect(values[2], values[3], values[2]+values[0], values[3]+values[1]), nil
+}
+
+func FormatAspect(aspect float64) string {
+	if aspect == 0 {
+		return "auto"
+	}
+	return strconv.FormatFloat(aspect, 'f', 2, 64)
+}
+
+// Switches to the next aspect ratio preset
+func (v *View) NextAspect() {
+	next := 0
+	for i, preset := range aspectPresets {
+		if math.Abs(preset-v.Aspect) < 1e-3 {
+			next = (i + 1) % len(aspectPresets)
+			break
+		}
+	}
+	v.Aspect = aspectPresets[next]
+}
+
+// Rotates the picture by 90 degrees clockwise (counter-clockwise if steps are negative)
+func (v *View) Rotate(steps int) {
+	v.Rotation = ((v.Rotation+steps*90)%360 + 360) % 360
+}
+
+func (v *View) rotatedSideways() bool {
+	return v.Rotation == 90 || v.Rotation == 270
+}
+
+// Shown part of the frame, whole frame if crop is empty or outside of it
+func (v *View) cropRect(frameWidth, frameHeight int32) image.Rectangle {
+	frame := image.Rect(0, 0, int(frameWidth), int(frameHeight))
+	crop := v.Crop.Intersect(frame)
+	if crop.Empty() {
+		return frame
+	}
+	return crop
+}
+
+// Half size of the shown (rotated) picture in normalized device coordinates
+func (v *View) halfSize(fbWidth, fbHeight int, frameWidth, frameHeight int32) (float64, float64) {
+	crop := v.cropRect(frameWidth, frameHeight)
+	var sx, sy float64
+	if v.PixelPerfect {
+		width, height := crop.Dx(), crop.Dy()
+		if v.rotatedSideways() {
+			width, height = height, width
+		}
+		sx = float64(width) / float64(fbWidth)
+		sy = float64(height) / float64(fbHeight)
+	} else {
+		aspect := v.Aspect
+		if aspect == 0 {
+			aspect = float64(frameWidth) / float64(frameHeight)
+			if v.SampleAspect > 0 {
+				aspect *= v.SampleAspect
+			}
+		}
+		// crop keeps the pixel shape
+		aspect *= float64(crop.Dx()) / float64(frameWidth) * float64(frameHeight) / float64(crop.Dy())
+		if v.rotatedSideways() {
+			aspect = 1 / aspect
+		}
+
+		windowAspect := float64(fbWidth) / float64(fbHeight)
+		if windowAspect >= aspect {
+			sx, sy = aspect/windowAspect, 1
+		} else {
+			sx, sy = 1, windowAspect/aspect
+		}
+	}
+	return sx * v.Zoom, sy * v.Zoom
+}
+
+// View matrix placing the shown part of the frame into the framebuffer
+func (v *View) Matrix(fbWidth, fbHeight int, frameWidth, frameHeight int32) mgl32.Mat4 {
+	if fbWidth <= 0 || fbHeight <= 0 || frameWidth <= 0 || frameHeight <= 0 {
+		return mgl32.Ident4()
+	}
+	sx, sy := v.halfSize(fbWidth, fbHeight, frameWidth, frameHeight)
+
+	// crop rectangle in quad coordinates
+	crop := v.cropRect(frameWidth, frameHeight)
+	cropWidth := float64(crop.Dx()) / float64(frameWidth)
+	cropHeight := float64(crop.Dy()) / float64(frameHeight)
+	centreX := float64(crop.Min.X+crop.Max.X)/float64(frameWidth) - 1
+	centreY := 1 - float64(crop.Min.Y+crop.Max.Y)/float64(frameHeight)
+
+	flipX, flipY := float32(1), float32(1)
+	if v.FlipH {
+		flipX = -1
+	}
+	if v.FlipV {
+		flipY = -1
+	}
+
+	// crop is moved to the centre and scaled to [-1, 1] square,
+	// which is rotated (clockwise is negative angle), flipped
+	// and stretched to the picture size
+	return mgl32.Translate3D(float32(v.PanX), float32(v.PanY), 0).
+		Mul4(mgl32.Scale3D(float32(sx), float32(sy), 1)).
+		Mul4(mgl32.Scale3D(flipX, flipY, 1)).
+		Mul4(mgl32.HomogRotate3DZ(mgl32.DegToRad(float32(-v.Rotation)))).
+		Mul4(mgl32.Scale3D(float32(1/cropWidth), float32(1/cropHeight), 1)).
+		Mul4(mgl32.Translate3D(float32(-centreX), float32(-centreY), 0))
+}
+
+// Rectangle of the shown picture in framebuffer pixels with origin at the top left corner
+// (like window coordinates), it exceeds the framebuffer if the picture is zoomed in
+func (v *View) PictureRect(fbWidth, fbHeight int, frameWidth, frameHeight int32) image.Rectangle {
+	if frameWidth <= 0 || frameHeight <= 0 {
+		return image.Rect(0, 0, fbWidth, fbHeight)
+	}
+	sx, sy := v.halfSize(fbWidth, fbHeight, frameWidth, frameHeight)
+	toPixels := func(ndc float64, size int) int {
+		return int(math.Round((ndc + 1) / 2 * float64(size)))
+	}
+	return image.Rect(
+		toPixels(v.PanX-sx, fbWidth), fbHeight-toPixels(v.PanY+sy, fbHeight),
+		toPixels(v.PanX+sx, fbWidth), fbHeight-toPixels(v.PanY-sy, fbHeight),
+	)
+}
+
+// Rectangle of the shown picture in framebuffer pixels
+// (origin at the bottom left corner), everything outside of it is cropped
+func (v *View) ClipRect(fbWidth, fbHeight int, frameWidth, frameHeight int32) image.Rectangle {
+	picture := v.PictureRect(fbWidth, fbHeight, frameWidth, frameHeight)
+	return image.Rect(
+		picture.Min.X, fbHeight-picture.Max.Y,
+		picture.Max.X, fbHeight-picture.Min.Y,
+	).Intersect(image.Rect(0, 0, fbWidth, fbHeight))
+}
+
+// Multiplies zoom keeping the point (in normalized device coordinates) in place
+func (v *View) ZoomAt(factor, x, y float64) {
+	zoom := math.Min(math.Max(v.Zoom*factor, minZoom), maxZoom)
+	factor = zoom / v.Zoom
+	v.PanX = x - (x-v.PanX)*factor
+	v.PanY = y - (y-v.PanY)*factor
+	v.Zoom = zoom
+}
+
+// Moves the picture by offset in normalized device coordinates
+func (v *View) Pan(dx, dy float64) {
+	v.PanX += dx
+	v.PanY += dy
+}
+
+// Fits the picture into the window again
+// (rotation and flips are kept)
+func (v *View) Reset() {
+	v.Zoom = 1
+	v.PanX, v.PanY = 0, 0
+	v.PixelPerfect = false
+}